@@ -8,7 +8,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-//go:embed *.json *.yaml
+//go:embed *.json *.yaml *.toml
 var content embed.FS
 
 func TestJSON(t *testing.T) {
@@ -27,6 +27,14 @@ func TestYAML(t *testing.T) {
 	checkList(t, nodup, s, "a", "b")
 }
 
+func TestTOML(t *testing.T) {
+	s, err := UnmarshalFile("common.toml", WithFS(content))
+	require.NoError(t, err, "open common.toml")
+	checkList(t, top, s)
+	checkList(t, stds, s, "a", "b")
+	checkList(t, nodup, s, "a", "b")
+}
+
 func TestRecurse1(t *testing.T) {
 	s, err := UnmarshalFile("common.yaml", WithFS(content))
 	require.NoError(t, err, "open common.yaml")
@@ -106,6 +114,11 @@ var stds = []expectation{
 		cmd:  "int",
 		want: int64(28),
 	},
+	{
+		path: []string{"i"},
+		cmd:  "uint",
+		want: uint64(28),
+	},
 	{
 		path: []string{"f"},
 		cmd:  "float",
@@ -209,6 +222,8 @@ func check(t *testing.T, want expectation, s Source, prefix ...string) {
 		v, err = s.GetBool(path...)
 	case "int":
 		v, err = s.GetInt(path...)
+	case "uint":
+		v, err = GetUInt(s, path...)
 	case "float":
 		v, err = s.GetFloat(path...)
 	case "string":
@@ -242,3 +257,58 @@ func check(t *testing.T, want expectation, s Source, prefix ...string) {
 	}
 	checkList(t, want.follow, s, prefix...)
 }
+
+func TestUIntWrappers(t *testing.T) {
+	big, err := UnmarshalJSON([]byte(`{"id":18446744073709551615,"ids":[1,18446744073709551614],"neg":-1}`))
+	require.NoError(t, err)
+	wrapped := map[string]Source{
+		"multi":  NewMultiSource(NewEnvSource("X_", "_", WithEnviron([]string{})), big),
+		"label":  WithLabel(big, "big.json"),
+		"prefix": NewPrefixSource(big.Recurse("ids"), "p"),
+		"offset": WithOffset(big.Recurse("ids"), 1),
+	}
+	keys := map[string][]string{
+		"multi":  {"id"},
+		"label":  {"id"},
+		"prefix": {"p", "1"},
+		"offset": {"2"},
+	}
+	for name, s := range wrapped {
+		u, err := GetUInt(s, keys[name]...)
+		if assert.NoError(t, err, name) {
+			assert.Greater(t, u, uint64(1<<63), name)
+		}
+	}
+	_, err = GetUInt(big, "neg")
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	got, err := MarshalJSON(big)
+	require.NoError(t, err)
+	assert.Contains(t, string(got), `"id":18446744073709551615`)
+
+	env := NewEnvSource("X_", "_", WithEnviron([]string{"X_SIZE=4096", "X_ID=18446744073709551615", "X_NEG=-2"}))
+	u, err := UIntFromInt(env, "size")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4096), u)
+	u, err = UIntFromInt(env, "id")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(18446744073709551615), u)
+	_, err = UIntFromInt(env, "neg")
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	// sources from outside this package need not implement UIntGetter
+	var plain Source = plainSource{env}
+	_, ok := plain.(UIntGetter)
+	require.False(t, ok)
+	u, err = GetUInt(NewMultiSource(plain), "id")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(18446744073709551615), u)
+	var sized struct{ Size uint16 }
+	require.NoError(t, Decode(WithLabel(plain, "env"), &sized))
+	assert.Equal(t, uint16(4096), sized.Size)
+}
+
+// plainSource has only the methods of Source
+type plainSource struct {
+	Source
+}
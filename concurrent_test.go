@@ -0,0 +1,60 @@
+package nflex
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentReads is most useful with -race.  Each round starts
+// with freshly parsed sources so that lazily built state is built
+// while other goroutines are reading.
+func TestConcurrentReads(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		y, err := UnmarshalFile("common.yaml", WithFS(content))
+		require.NoError(t, err, "open common.yaml")
+		y2, err := UnmarshalFile("common.yaml", WithFS(content))
+		require.NoError(t, err, "open common.yaml")
+		j, err := UnmarshalFile("common.json", WithFS(content))
+		require.NoError(t, err, "open common.json")
+		tm, err := UnmarshalFile("common.toml", WithFS(content))
+		require.NoError(t, err, "open common.toml")
+		env := NewEnvSource("APP_", "__", WithEnviron([]string{"APP_A__B__E=env"}))
+		m := NewMultiSource(env, y2, j, tm)
+
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				for _, s := range []Source{y, m} {
+					keys, err := s.Keys("a", "b")
+					if assert.NoError(t, err) {
+						assert.Contains(t, keys, "m")
+					}
+					str, err := s.GetString("a", "b", "m", "k2")
+					assert.NoError(t, err)
+					assert.Equal(t, "v2", str)
+					r := s.Recurse("a", "b", "d")
+					if assert.NotNil(t, r) {
+						_, err = r.Keys()
+						assert.NoError(t, err)
+					}
+					assert.Equal(t, Slice, s.Type("a", "b", "a"))
+					_, _, _, ok := PositionOf(s, "a", "b", "s")
+					assert.True(t, ok)
+					_, err = s.GetString("a", "b", "nope")
+					assert.ErrorIs(t, err, ErrDoesNotExist)
+				}
+				_, err := MarshalJSON(m)
+				assert.NoError(t, err)
+			}()
+		}
+		close(start)
+		wg.Wait()
+	}
+}
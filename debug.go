@@ -38,6 +38,12 @@ func id(raw Source) string {
 		return fmt.Sprintf("J%d/%v", s.debugID, s.pathToHere)
 	case parsedJSON:
 		return fmt.Sprintf("J%d/%v", s.debugID, s.pathToHere)
+	case valueSource:
+		return fmt.Sprintf("V%d/%v", s.debugID, s.pathToHere)
+	case labeledSource:
+		return fmt.Sprintf("L%d/%s", s.debugID, id(s.source))
+	case treeSource:
+		return fmt.Sprintf("T%d/%v", s.debugID, s.pathToHere)
 	case *MultiSource:
 		ss := make([]string, len(s.sources))
 		for i, source := range s.sources {
@@ -49,8 +55,6 @@ func id(raw Source) string {
 	}
 }
 
-var debugCombine = combine
-
 func debugKeys(s Source) string {
 	switch s.Type() {
 	case Undefined:
@@ -0,0 +1,628 @@
+package nflex
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type decodeOpts struct {
+	strict bool
+	hooks  map[reflect.Type]DecodeHook
+}
+
+type DecodeArg func(*decodeOpts)
+
+// DecodeHook decodes a value of a type that Decode does not know how
+// to decode.  The source is the part of the Source being decoded that
+// holds the value.  The returned value must be assignable to t.
+type DecodeHook func(source Source, t reflect.Type) (reflect.Value, error)
+
+// WithDecodeHook makes Decode use hook for values of type t, which
+// can be a pointer type.  Hooks are used before any other way of
+// decoding a value, but not for values that are missing or null.
+// If the Source cannot Recurse to the value, the hook is not called
+// and Decode reports an error that matches ErrDoesNotExist.
+//
+//	nflex.WithDecodeHook(reflect.TypeOf(tls.Config{}),
+//		func(source nflex.Source, t reflect.Type) (reflect.Value, error) {
+//			...
+//		})
+func WithDecodeHook(t reflect.Type, hook DecodeHook) DecodeArg {
+	return func(o *decodeOpts) {
+		if o.hooks == nil {
+			o.hooks = make(map[reflect.Type]DecodeHook)
+		}
+		o.hooks[t] = hook
+	}
+}
+
+// WithStrict makes Decode report every key of a map that is decoded
+// into a struct that does not match a field of the struct.  Each
+// one is a FieldError that matches ErrUnknownKey and has the path
+// and, if the source knows it, the position of the key.  With a
+// MultiSource, the keys from all of its sources are checked.
+func WithStrict() DecodeArg {
+	return func(o *decodeOpts) {
+		o.strict = true
+	}
+}
+
+// FieldError is a failure to decode one path
+type FieldError struct {
+	Path []string
+	Err  error
+}
+
+func (e *FieldError) Error() string { return fmt.Sprintf("decode %v: %s", e.Path, e.Err) }
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// DecodeError is returned by Decode when some paths could not be
+// decoded.  Decode keeps going after an error so that every problem
+// is reported at once.
+type DecodeError struct {
+	Errors []*FieldError
+}
+
+func (e *DecodeError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *DecodeError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// Decode fills target, which must be a non-nil pointer, from source.
+//
+// Struct fields are named with `nflex:"name"` tags.  Fields without
+// a tag use the lowercased field name.  Fields tagged `nflex:"-"`
+// and unexported fields are skipped.  Embedded structs without a
+// name in their tag have their fields decoded as if they were
+// fields of the outer struct.
+//
+// Paths that do not exist in source leave the corresponding value
+// unchanged.  Nil values set the corresponding value to its zero
+// value.
+//
+// Options follow the name in the tag and change what happens when
+// a field's key does not exist.  A key that exists with a null value
+// is not missing.
+//
+//	Port    int      `nflex:"port,default=8080"`
+//	Hosts   []string `nflex:"hosts,default=[a, b]"`
+//	Name    string   `nflex:"name,required"`
+//
+// "default=" decodes its value, which is parsed as YAML, into the
+// field.  It must be the last option because everything after
+// "default=" is the value, including commas.  "required" adds an
+// error, that matches ErrRequired, for each missing field.  Decode
+// ignores "omitempty", which only changes NewStructSource.  The
+// fields of a struct are checked even when the struct's own key is
+// missing unless the struct is behind a nil pointer.
+//
+// time.Duration and time.Time values are read with GetDuration and
+// GetTime.  url.URL values are parsed with url.Parse.  Types that
+// implement encoding.TextUnmarshaler are given the text of scalar
+// values.  Other types can be decoded with WithDecodeHook.
+//
+// Decode only uses the Source interface so it works the same over
+// any Source, including MultiSource.
+func Decode(source Source, target interface{}, args ...DecodeArg) error {
+	var opts decodeOpts
+	for _, f := range args {
+		f(&opts)
+	}
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.Errorf("decode target must be a non-nil pointer, not %T", target)
+	}
+	d := decoder{
+		source: source,
+		opts:   opts,
+	}
+	d.decode(nil, v.Elem())
+	if len(d.errors) != 0 {
+		return &DecodeError{Errors: d.errors}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var timeType = reflect.TypeOf(time.Time{})
+var urlType = reflect.TypeOf(url.URL{})
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+type decoder struct {
+	source Source
+	opts   decodeOpts
+	errors []*FieldError
+}
+
+func (d *decoder) fail(path []string, err error) {
+	d.errors = append(d.errors, &FieldError{
+		Path: path,
+		Err:  err,
+	})
+}
+
+func (d *decoder) decode(path []string, v reflect.Value) {
+	nodeType := d.source.Type(path...)
+	switch nodeType {
+	case Undefined:
+		if v.Kind() == reflect.Struct && v.Type() != timeType {
+			// for defaults and required fields
+			d.decodeStruct(path, v)
+		}
+		return
+	case Nil:
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	if hook, ok := d.opts.hooks[v.Type()]; ok {
+		d.decodeHook(path, v, hook)
+		return
+	}
+	switch v.Type() {
+	case durationType:
+		dur, err := GetDuration(d.source, path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		v.SetInt(int64(dur))
+		return
+	case timeType:
+		t, err := GetTime(d.source, path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		v.Set(reflect.ValueOf(t))
+		return
+	case urlType:
+		s, err := d.source.GetString(path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			d.fail(path, errors.Wrapf(ErrWrongType, "key %v: %s", path, err))
+			return
+		}
+		v.Set(reflect.ValueOf(*u))
+		return
+	}
+	if nodeType != Map && nodeType != Slice && v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		d.decodeText(path, v)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.decode(path, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			d.fail(path, errors.Errorf("cannot decode into non-empty interface %s", v.Type()))
+			return
+		}
+		i, err := ToInterface(d.source, path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		if i == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		v.Set(reflect.ValueOf(i))
+	case reflect.Struct:
+		if nodeType != Map {
+			d.fail(path, errors.Wrapf(ErrWrongType, "key %v is a %s (not a map)", path, nodeType))
+			return
+		}
+		if d.opts.strict {
+			d.unknownKeys(path, v.Type())
+		}
+		d.decodeStruct(path, v)
+	case reflect.Map:
+		d.decodeMap(path, v, nodeType)
+	case reflect.Slice:
+		d.decodeSlice(path, v)
+	case reflect.Array:
+		d.decodeArray(path, v)
+	case reflect.Bool:
+		b, err := d.source.GetBool(path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := d.source.GetInt(path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		if v.OverflowInt(i) {
+			d.fail(path, errors.Wrapf(ErrWrongType, "key %v value %d overflows %s", path, i, v.Type()))
+			return
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := GetUInt(d.source, path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		if v.OverflowUint(u) {
+			d.fail(path, errors.Wrapf(ErrWrongType, "key %v value %d overflows %s", path, u, v.Type()))
+			return
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := d.source.GetFloat(path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		if v.OverflowFloat(f) {
+			d.fail(path, errors.Wrapf(ErrWrongType, "key %v value %g overflows %s", path, f, v.Type()))
+			return
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, err := d.source.GetString(path...)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		v.SetString(s)
+	default:
+		d.fail(path, errors.Errorf("cannot decode into %s", v.Type()))
+	}
+}
+
+func (d *decoder) decodeStruct(path []string, v reflect.Value) {
+	for _, field := range structFields(v.Type()) {
+		fv := v.Field(field.index)
+		if field.inline {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			d.decodeStruct(path, fv)
+			continue
+		}
+		fieldPath := combine(path, []string{field.name})
+		if !d.source.Exists(fieldPath...) {
+			switch {
+			case field.hasDefault:
+				d.decodeDefault(fieldPath, fv, field.defaultValue)
+				continue
+			case field.required:
+				var near string
+				if p, ok := d.source.(Positioner); ok {
+					near = nearSuffix(p, fieldPath)
+				}
+				d.fail(fieldPath, errors.Wrapf(ErrRequired, "key %v is missing%s", fieldPath, near))
+				continue
+			}
+		}
+		d.decode(fieldPath, fv)
+	}
+}
+
+// decodeHook uses a DecodeHook for the value at path
+func (d *decoder) decodeHook(path []string, v reflect.Value, hook DecodeHook) {
+	source := d.source.Recurse(path...)
+	if source == nil {
+		d.fail(path, errors.Wrapf(ErrDoesNotExist, "cannot recurse into key %v for decode hook", path))
+		return
+	}
+	r, err := hook(source, v.Type())
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	if !r.IsValid() || !r.Type().AssignableTo(v.Type()) {
+		d.fail(path, errors.Errorf("decode hook for %s returned %s", v.Type(), r))
+		return
+	}
+	v.Set(r)
+}
+
+// decodeText uses UnmarshalText for the scalar at path
+func (d *decoder) decodeText(path []string, v reflect.Value) {
+	text, err := scalarText(d.source, path)
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	err = v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	if err != nil {
+		d.fail(path, errors.Wrapf(ErrWrongType, "key %v: %s", path, err))
+	}
+}
+
+// scalarText returns the text of a scalar of any type
+func scalarText(source Source, path []string) (string, error) {
+	switch source.Type(path...) {
+	case Int:
+		i, err := ToInterface(source, path...)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprint(i), nil
+	case Float:
+		f, err := source.GetFloat(path...)
+		if err != nil {
+			return "", err
+		}
+		return formatFloat(f), nil
+	case Bool:
+		b, err := source.GetBool(path...)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	default:
+		return source.GetString(path...)
+	}
+}
+
+// unknownKeys reports the keys at path that are not fields of t
+func (d *decoder) unknownKeys(path []string, t reflect.Type) {
+	keys, err := d.source.Keys(path...)
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	known := make(map[string]bool)
+	fieldNames(t, known)
+	for _, key := range keys {
+		if known[key] {
+			continue
+		}
+		keyPath := combine(path, []string{key})
+		d.fail(keyPath, errors.Wrapf(ErrUnknownKey, "key %v does not match a field of %s%s",
+			keyPath, t, positionSuffix(keyPositionOf(d.source, keyPath...))))
+	}
+}
+
+// fieldNames adds the names of the fields of a struct type,
+// including the fields of embedded structs, to names
+func fieldNames(t reflect.Type, names map[string]bool) {
+	for _, field := range structFields(t) {
+		if field.inline {
+			ft := t.Field(field.index).Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			fieldNames(ft, names)
+			continue
+		}
+		names[field.name] = true
+	}
+}
+
+// decodeDefault decodes the default value from a struct tag
+func (d *decoder) decodeDefault(path []string, v reflect.Value, text string) {
+	source, err := UnmarshalYAML([]byte(text))
+	if err != nil {
+		d.fail(path, errors.Wrap(err, "default"))
+		return
+	}
+	if strings.TrimSpace(text) == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	dd := decoder{
+		source: source,
+		opts:   d.opts,
+	}
+	dd.decode(nil, v)
+	for _, fe := range dd.errors {
+		d.fail(combine(path, fe.Path), errors.Wrap(fe.Err, "default"))
+	}
+}
+
+func (d *decoder) decodeMap(path []string, v reflect.Value, nodeType NodeType) {
+	if v.Type().Key().Kind() != reflect.String {
+		d.fail(path, errors.Errorf("cannot decode into %s: keys must be strings", v.Type()))
+		return
+	}
+	if nodeType != Map {
+		d.fail(path, errors.Wrapf(ErrWrongType, "key %v is a %s (not a map)", path, nodeType))
+		return
+	}
+	keys, err := d.source.Keys(path...)
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(keys)))
+	}
+	for _, key := range keys {
+		e := reflect.New(v.Type().Elem()).Elem()
+		d.decode(combine(path, []string{key}), e)
+		v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), e)
+	}
+}
+
+func (d *decoder) decodeSlice(path []string, v reflect.Value) {
+	length, err := d.source.Len(path...)
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	s := reflect.MakeSlice(v.Type(), length, length)
+	for i := 0; i < length; i++ {
+		d.decode(combine(path, []string{strconv.Itoa(i)}), s.Index(i))
+	}
+	v.Set(s)
+}
+
+func (d *decoder) decodeArray(path []string, v reflect.Value) {
+	length, err := d.source.Len(path...)
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	if length > v.Len() {
+		d.fail(path, errors.Wrapf(ErrWrongType, "key %v has %d elements, too many for %s", path, length, v.Type()))
+		return
+	}
+	for i := 0; i < v.Len(); i++ {
+		if i < length {
+			d.decode(combine(path, []string{strconv.Itoa(i)}), v.Index(i))
+		} else {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	}
+}
+
+type fieldInfo struct {
+	index        int
+	name         string
+	inline       bool
+	required     bool
+	omitEmpty    bool
+	hasDefault   bool
+	defaultValue string
+}
+
+// structFields lists the fields of a struct type that nflex
+// decodes, using `nflex` struct tags.
+func structFields(t reflect.Type) []fieldInfo {
+	fields := make([]fieldInfo, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("nflex")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !f.IsExported() && f.Type.Kind() == reflect.Ptr {
+					continue
+				}
+				fields = append(fields, fieldInfo{
+					index:  i,
+					inline: true,
+				})
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		field := fieldInfo{
+			index: i,
+			name:  name,
+		}
+		for options != "" {
+			if value, ok := strings.CutPrefix(options, "default="); ok {
+				field.hasDefault = true
+				field.defaultValue = value
+				break
+			}
+			var option string
+			option, options, _ = strings.Cut(options, ",")
+			switch option {
+			case "required":
+				field.required = true
+			case "omitempty":
+				field.omitEmpty = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// ToInterface converts a Source, or part of a Source, into Go
+// values: map[string]interface{}, []interface{}, int64, float64,
+// string, bool, and nil.  Integers that do not fit in an int64 are
+// uint64.  The result can be handed to libraries that take
+// map[string]interface{} and can be turned back into a Source with
+// NewValueSource.
+func ToInterface(source Source, keys ...string) (interface{}, error) {
+	switch t := source.Type(keys...); t {
+	case Undefined:
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+	case Nil:
+		return nil, nil
+	case Bool:
+		return source.GetBool(keys...)
+	case Int:
+		i, err := source.GetInt(keys...)
+		if err != nil {
+			if u, uerr := GetUInt(source, keys...); uerr == nil {
+				return u, nil
+			}
+			return nil, err
+		}
+		return i, nil
+	case Float:
+		return source.GetFloat(keys...)
+	case String:
+		return source.GetString(keys...)
+	case Map:
+		mk, err := source.Keys(keys...)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(mk))
+		for _, k := range mk {
+			e, err := ToInterface(source, combine(keys, []string{k})...)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = e
+		}
+		return m, nil
+	case Slice:
+		length, err := source.Len(keys...)
+		if err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, length)
+		for i := range a {
+			a[i], err = ToInterface(source, combine(keys, []string{strconv.Itoa(i)})...)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	default:
+		return nil, errors.Errorf("key %v has unknown type %s", keys, t)
+	}
+}
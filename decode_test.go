@@ -0,0 +1,385 @@
+package nflex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCommon struct {
+	I  int               `nflex:"i"`
+	F  float32           `nflex:"f"`
+	S  string            `nflex:"s"`
+	BT bool              `nflex:"bt"`
+	BF *bool             `nflex:"bf"`
+	A  []uint8           `nflex:"a"`
+	M  map[string]string `nflex:"m"`
+	D  interface{}       `nflex:"d"`
+}
+
+type testOuter struct {
+	A struct {
+		B testCommon
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, file := range []string{"common.yaml", "common.json", "common.toml"} {
+		s, err := UnmarshalFile(file, WithFS(content))
+		require.NoError(t, err, file)
+		var got testOuter
+		require.NoError(t, Decode(s, &got), file)
+		b := got.A.B
+		assert.Equal(t, 28, b.I, file)
+		assert.Equal(t, float32(34.39), b.F, file)
+		assert.Equal(t, "foo", b.S, file)
+		assert.True(t, b.BT, file)
+		if assert.NotNil(t, b.BF, file) {
+			assert.False(t, *b.BF, file)
+		}
+		assert.Equal(t, []uint8{3, 11}, b.A, file)
+		assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, b.M, file)
+		assert.Len(t, b.D, 1, file)
+	}
+}
+
+func TestDecodeWrappers(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+
+	var multi testOuter
+	require.NoError(t, Decode(NewMultiSource(j, y), &multi))
+	assert.Equal(t, []uint8{3, 11, 3, 11}, multi.A.B.A)
+	assert.Equal(t, map[string]interface{}{"j": "json", "y": "yaml"}, multi.A.B.D)
+
+	var prefixed testOuter
+	require.NoError(t, Decode(NewPrefixSource(y.Recurse("a", "b"), "a", "b"), &prefixed))
+	assert.Equal(t, 28, prefixed.A.B.I)
+
+	var offset []string
+	r, err := UnmarshalFile("rdata1.yaml", WithFS(content))
+	require.NoError(t, err, "open rdata1.yaml")
+	require.NoError(t, Decode(WithOffset(r.Recurse("QQ"), 0), &offset))
+	assert.Equal(t, []string{"a", "b", "c"}, offset)
+}
+
+type testEmbedded struct {
+	Name string
+}
+
+type testDecodeTarget struct {
+	testEmbedded
+	Port    uint16
+	Ratio   float64
+	Skip    string `nflex:"-"`
+	Nested  *testEmbedded
+	Servers []testEmbedded
+	Fixed   [3]int
+	Null    *int
+	ignored string
+}
+
+func TestDecodeShapes(t *testing.T) {
+	s, err := UnmarshalJSON([]byte(`{
+		"name": "outer",
+		"port": 8080,
+		"ratio": 2,
+		"skip": "nope",
+		"nested": {"name": "inner"},
+		"servers": [{"name": "a"}, {"name": "b"}],
+		"fixed": [1, 2],
+		"null": null,
+		"ignored": "nope"
+	}`))
+	require.NoError(t, err)
+	seven := 7
+	got := testDecodeTarget{Fixed: [3]int{9, 9, 9}, Null: &seven}
+	require.NoError(t, Decode(s, &got))
+	assert.Equal(t, testDecodeTarget{
+		testEmbedded: testEmbedded{Name: "outer"},
+		Port:         8080,
+		Ratio:        2,
+		Nested:       &testEmbedded{Name: "inner"},
+		Servers:      []testEmbedded{{Name: "a"}, {Name: "b"}},
+		Fixed:        [3]int{1, 2, 0},
+	}, got)
+}
+
+func TestDecodeMissingKeys(t *testing.T) {
+	type target struct {
+		A int
+		B string
+	}
+	y, err := UnmarshalYAML([]byte("a: 1\n"))
+	require.NoError(t, err)
+	j, err := UnmarshalJSON([]byte(`{"a": 1}`))
+	require.NoError(t, err)
+	for _, s := range []Source{y, j} {
+		assert.Equal(t, Undefined, s.Type("b"))
+		got := target{A: 5, B: "keep"}
+		require.NoError(t, Decode(s, &got))
+		assert.Equal(t, target{A: 1, B: "keep"}, got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(`
+name: [not, a, string]
+port: 70000
+ratio: high
+servers:
+  - name: ok
+  - name: {x: y}
+`))
+	require.NoError(t, err)
+	var got testDecodeTarget
+	err = Decode(s, &got)
+	require.Error(t, err)
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	paths := make([][]string, len(de.Errors))
+	for i, fe := range de.Errors {
+		paths[i] = fe.Path
+	}
+	assert.Equal(t, [][]string{{"name"}, {"port"}, {"ratio"}, {"servers", "1", "name"}}, paths)
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.Equal(t, "ok", got.Servers[0].Name)
+
+	assert.Error(t, Decode(s, got), "not a pointer")
+}
+
+func TestDecodeTimes(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(`
+timeout: 1m
+retry: 250ms
+expires: 2026-01-01T00:00:00Z
+`))
+	require.NoError(t, err)
+	var got struct {
+		Timeout time.Duration
+		Retry   *time.Duration
+		Expires time.Time
+	}
+	require.NoError(t, Decode(s, &got))
+	assert.Equal(t, time.Minute, got.Timeout)
+	if assert.NotNil(t, got.Retry) {
+		assert.Equal(t, 250*time.Millisecond, *got.Retry)
+	}
+	assert.True(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Equal(got.Expires), got.Expires)
+
+	bad, err := UnmarshalJSON([]byte(`{"timeout":"soon","expires":3}`))
+	require.NoError(t, err)
+	err = Decode(bad, &got)
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	require.Len(t, de.Errors, 2)
+	assert.ErrorIs(t, err, ErrWrongType)
+}
+
+type testOptions struct {
+	Port     int           `nflex:"port,default=8080"`
+	Hosts    []string      `nflex:"hosts,default=[a, b]"`
+	Timeout  time.Duration `nflex:"timeout,default=30s"`
+	Greeting string        `nflex:"greeting,default=hello, world"`
+	Name     string        `nflex:"name,required"`
+	Comment  string        `nflex:"comment,omitempty"`
+	DB       struct {
+		Host string `nflex:"host,required"`
+		Port int    `nflex:"port,default=5432"`
+	} `nflex:"db"`
+	Cache *struct {
+		Size int `nflex:"size,required"`
+	} `nflex:"cache"`
+}
+
+func TestDecodeOptions(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(`
+name: svc
+port: ~
+db:
+  host: localhost
+`))
+	require.NoError(t, err)
+	got := testOptions{Port: 1}
+	require.NoError(t, Decode(s, &got))
+	assert.Equal(t, 0, got.Port, "null is not missing")
+	assert.Equal(t, []string{"a", "b"}, got.Hosts)
+	assert.Equal(t, 30*time.Second, got.Timeout)
+	assert.Equal(t, "hello, world", got.Greeting)
+	assert.Equal(t, "svc", got.Name)
+	assert.Equal(t, "", got.Comment)
+	assert.Equal(t, "localhost", got.DB.Host)
+	assert.Equal(t, 5432, got.DB.Port)
+	assert.Nil(t, got.Cache)
+
+	empty, err := UnmarshalJSON([]byte(`{"cache":{}}`))
+	require.NoError(t, err)
+	got = testOptions{}
+	err = Decode(empty, &got)
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	paths := make([][]string, len(de.Errors))
+	for i, fe := range de.Errors {
+		paths[i] = fe.Path
+		assert.ErrorIs(t, fe, ErrRequired)
+	}
+	assert.Equal(t, [][]string{{"name"}, {"db", "host"}, {"cache", "size"}}, paths)
+	assert.Equal(t, 8080, got.Port)
+	assert.Equal(t, 5432, got.DB.Port, "defaults inside a missing struct")
+
+	type badDefault struct {
+		Port int `nflex:"port,default=eighty"`
+	}
+	err = Decode(empty, &badDefault{})
+	require.ErrorAs(t, err, &de)
+	require.Len(t, de.Errors, 1)
+	assert.Equal(t, []string{"port"}, de.Errors[0].Path)
+	assert.ErrorIs(t, err, ErrWrongType)
+}
+
+func TestDecodeStrict(t *testing.T) {
+	base, err := UnmarshalYAML([]byte(`
+name: svc
+timout: 30s
+servers:
+  - name: a
+    prot: 80
+`))
+	require.NoError(t, err)
+	overlay, err := UnmarshalJSON([]byte(`{"port": 8080, "nested": {"name": "x", "extra": true}}`))
+	require.NoError(t, err)
+	s := NewMultiSource(WithLabel(overlay, "overlay.json"), WithLabel(base, "base.yaml"))
+
+	var got testDecodeTarget
+	require.NoError(t, Decode(s, &got), "not strict by default")
+
+	got = testDecodeTarget{}
+	err = Decode(s, &got, WithStrict())
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	paths := make([][]string, len(de.Errors))
+	for i, fe := range de.Errors {
+		paths[i] = fe.Path
+		assert.ErrorIs(t, fe, ErrUnknownKey)
+	}
+	assert.Equal(t, [][]string{{"timout"}, {"nested", "extra"}, {"servers", "0", "prot"}}, paths)
+	assert.Contains(t, de.Errors[0].Error(), "base.yaml:3:1", "position of the key")
+	assert.Contains(t, de.Errors[1].Error(), "overlay.json:1:40")
+	assert.Contains(t, de.Errors[2].Error(), "base.yaml:6:5")
+	assert.Equal(t, "svc", got.Name, "decoding continues")
+	assert.Equal(t, uint16(8080), got.Port)
+
+	var loose map[string]interface{}
+	require.NoError(t, Decode(s, &loose, WithStrict()), "maps take any key")
+}
+
+type testLevel int
+
+func (l *testLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = 1
+	case "info":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+	return nil
+}
+
+type testTLSConfig struct {
+	files []string
+}
+
+func TestDecodeText(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(`
+ip: 10.0.0.1
+endpoint: https://example.com/api?x=1
+match: ^a+$
+level: debug
+levels: [info, debug]
+port: 8080
+tls:
+  cert: /etc/cert.pem
+  key: /etc/key.pem
+`))
+	require.NoError(t, err)
+	var got struct {
+		IP       net.IP
+		Endpoint *url.URL
+		Match    *regexp.Regexp
+		Level    testLevel
+		Levels   []testLevel
+		Port     json.Number
+		TLS      *testTLSConfig
+	}
+	hook := func(source Source, typ reflect.Type) (reflect.Value, error) {
+		assert.Equal(t, reflect.TypeOf(&testTLSConfig{}), typ)
+		var c testTLSConfig
+		for _, k := range []string{"cert", "key"} {
+			f, err := source.GetString(k)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			c.files = append(c.files, f)
+		}
+		return reflect.ValueOf(&c), nil
+	}
+	require.NoError(t, Decode(s, &got, WithDecodeHook(reflect.TypeOf(&testTLSConfig{}), hook), WithStrict()))
+	assert.Equal(t, "10.0.0.1", got.IP.String())
+	if assert.NotNil(t, got.Endpoint) {
+		assert.Equal(t, "example.com", got.Endpoint.Host)
+		assert.Equal(t, "1", got.Endpoint.Query().Get("x"))
+	}
+	if assert.NotNil(t, got.Match) {
+		assert.True(t, got.Match.MatchString("aaa"))
+	}
+	assert.Equal(t, testLevel(1), got.Level)
+	assert.Equal(t, []testLevel{2, 1}, got.Levels)
+	assert.Equal(t, json.Number("8080"), got.Port)
+	assert.Equal(t, &testTLSConfig{files: []string{"/etc/cert.pem", "/etc/key.pem"}}, got.TLS)
+
+	bad, err := UnmarshalJSON([]byte(`{"level":"loud","match":"(","ip":"x","endpoint":":"}`))
+	require.NoError(t, err)
+	err = Decode(bad, &got)
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	assert.Len(t, de.Errors, 4)
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	failing := WithDecodeHook(reflect.TypeOf(testLevel(0)), func(Source, reflect.Type) (reflect.Value, error) {
+		return reflect.ValueOf("wrong type"), nil
+	})
+	err = Decode(s, &got, failing)
+	require.ErrorAs(t, err, &de)
+	assert.Len(t, de.Errors, 3, "level and two levels")
+
+	called := false
+	err = Decode(noRecurseSource{s}, &got, WithDecodeHook(reflect.TypeOf(&testTLSConfig{}),
+		func(Source, reflect.Type) (reflect.Value, error) {
+			called = true
+			return reflect.ValueOf(&testTLSConfig{}), nil
+		}))
+	require.ErrorAs(t, err, &de)
+	require.Len(t, de.Errors, 1)
+	assert.Equal(t, []string{"tls"}, de.Errors[0].Path)
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+	assert.False(t, called)
+}
+
+// noRecurseSource cannot Recurse
+type noRecurseSource struct {
+	Source
+}
+
+func (noRecurseSource) Recurse(...string) Source { return nil }
@@ -0,0 +1,75 @@
+package nflex
+
+// DeletionMarker reports whether a value in a source of a MultiSource
+// marks its key as deleted.  A deleted key does not exist in the
+// MultiSource: it is Undefined, it is left out of Keys, and the
+// sources with lower priority are not used for it.  Markers only
+// apply to keys of maps.  See MultiSourceSetDeletionMarker.
+type DeletionMarker func(Source) bool
+
+// MultiSourceSetDeletionMarker sets how a higher priority source of a
+// MultiSource can remove a key that a lower priority source provides.
+// By default nothing is a deletion marker.  Pass nil to go back to
+// that.
+//
+//	# overlay.yaml
+//	debug: !delete
+//
+//	m := MultiSourceSetDeletionMarker(DeleteOnTag("!delete")).
+//		Apply(NewMultiSource(overlay, base))
+func MultiSourceSetDeletionMarker(marker DeletionMarker) Mutation {
+	return func(source Source) Source {
+		if m, ok := source.(*MultiSource); ok {
+			c := m.Copy()
+			c.deletion = marker
+			return c
+		}
+		return source
+	}
+}
+
+// DeleteOnTag marks values that have a YAML tag, like "!delete"
+func DeleteOnTag(tag string) DeletionMarker {
+	return func(source Source) bool {
+		return TagOf(source) == tag
+	}
+}
+
+// DeleteOnNull marks values that are null.  This is the usual
+// convention for JSON overlays.
+func DeleteOnNull(source Source) bool {
+	return source.Type() == Nil
+}
+
+// DeleteOnValue marks values that are a particular string
+func DeleteOnValue(sentinel string) DeletionMarker {
+	return func(source Source) bool {
+		if source.Type() != String {
+			return false
+		}
+		s, err := source.GetString()
+		return err == nil && s == sentinel
+	}
+}
+
+// undeleted removes the children at and below the highest priority
+// deletion marker.  Children are in the order of m.sources.
+func (m *MultiSource) undeleted(children []Source) []Source {
+	if m.deletion == nil || len(children) == 0 || m.Type() != Map {
+		return children
+	}
+	if m.first {
+		for i, c := range children {
+			if m.deletion(c) {
+				return children[:i]
+			}
+		}
+		return children
+	}
+	for i := len(children) - 1; i >= 0; i-- {
+		if m.deletion(children[i]) {
+			return children[i+1:]
+		}
+	}
+	return children
+}
@@ -0,0 +1,76 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deletionBase = `{"debug":true,"name":"base","db":{"host":"localhost","port":5432},"tags":["a"]}`
+
+func TestDeleteOnTag(t *testing.T) {
+	base, err := UnmarshalJSON([]byte(deletionBase))
+	require.NoError(t, err)
+	overlay, err := UnmarshalYAML([]byte(`
+debug: !delete
+db:
+  port: !delete ~
+  user: app
+`))
+	require.NoError(t, err)
+
+	m := MultiSourceSetDeletionMarker(DeleteOnTag("!delete")).
+		Apply(NewMultiSource(overlay, base))
+	assert.False(t, m.Exists("debug"))
+	assert.Equal(t, Undefined, m.Type("db", "port"))
+	_, err = m.GetInt("db", "port")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+	assert.Equal(t, []string{"db", "name", "tags"}, mustKeys(t, m))
+	assert.Equal(t, []string{"user", "host"}, mustKeys(t, m, "db"))
+
+	got, err := MarshalJSON(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{"db":{"user":"app","host":"localhost"},"name":"base","tags":["a"]}`, string(got))
+
+	_, ok := m.(*MultiSource).Explain("debug")
+	assert.False(t, ok, "explain deleted")
+
+	plain := NewMultiSource(overlay, base)
+	assert.True(t, plain.Exists("db", "port"), "off by default")
+}
+
+func TestDeleteOnNull(t *testing.T) {
+	base, err := UnmarshalJSON([]byte(deletionBase))
+	require.NoError(t, err)
+	overlay, err := UnmarshalJSON([]byte(`{"name":null,"db":{"host":null}}`))
+	require.NoError(t, err)
+
+	m := MultiSourceSetDeletionMarker(DeleteOnNull).
+		Apply(NewMultiSource(overlay, base))
+	got, err := MarshalJSON(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{"db":{"port":5432},"debug":true,"tags":["a"]}`, string(got))
+
+	// with the last source winning, the overlay is the last source
+	last := MultiSourceSetFirst(false).
+		Combine(MultiSourceSetDeletionMarker(DeleteOnNull)).
+		Apply(NewMultiSource(base, overlay))
+	assert.False(t, last.Exists("name"))
+	assert.True(t, last.Exists("debug"))
+
+	off := MultiSourceSetDeletionMarker(nil).Apply(m)
+	assert.Equal(t, Nil, off.Type("name"))
+}
+
+func TestDeleteOnValue(t *testing.T) {
+	base, err := UnmarshalJSON([]byte(deletionBase))
+	require.NoError(t, err)
+	overlay := NewEnvSource("APP_", "__", WithEnviron([]string{"APP_NAME=-", "APP_DB__HOST=-"}))
+
+	m := MultiSourceSetDeletionMarker(DeleteOnValue("-")).
+		Apply(NewMultiSource(overlay, base))
+	assert.False(t, m.Exists("name"))
+	assert.False(t, m.Exists("db", "host"))
+	assert.Equal(t, []string{"port"}, mustKeys(t, m, "db"))
+}
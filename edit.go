@@ -0,0 +1,386 @@
+package nflex
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/valyala/fastjson"
+	"gopkg.in/yaml.v3"
+)
+
+// MutableSource is implemented by sources that can be edited in
+// place.  Sources parsed from YAML and JSON are mutable.  Edits are
+// visible to every Source derived from the same document and the
+// edited document can be written back with MarshalYAML or
+// MarshalJSON.
+//
+// Values may be nil, Go scalars, maps, slices, structs, or another
+// Source.  Set creates maps for keys that do not exist yet.  Set
+// can append to a slice by using its length as the index.
+//
+// Edits are not safe to make while other goroutines are reading
+// the same document.  Once a JSON document is edited, Position no
+// longer reports positions for any of its values.
+type MutableSource interface {
+	Source
+	Set(value interface{}, keys ...string) error
+	Delete(keys ...string) error
+}
+
+var _ MutableSource = parsedYAML{}
+var _ MutableSource = parsedJSON{}
+
+func (p parsedYAML) Set(value interface{}, keys ...string) error {
+	n, err := yamlValue(value)
+	if err != nil {
+		return errors.Wrapf(err, "set %v", combine(p.pathToHere, keys))
+	}
+	p.cache.snapshot()
+	if len(keys) == 0 {
+		root := p.root
+		if root.Kind == 0 || root.Kind == yaml.DocumentNode {
+			root.Kind = yaml.DocumentNode
+			if len(root.Content) == 0 {
+				root.Content = []*yaml.Node{n}
+				return nil
+			}
+			root = root.Content[0]
+		}
+		p.cache.replace(root, n)
+		return nil
+	}
+	parent, err := p.editable(keys[:len(keys)-1], true)
+	if err != nil {
+		return err
+	}
+	key := keys[len(keys)-1]
+	switch parent.Kind {
+	case yaml.MappingNode:
+		// the last value wins when a key is repeated, as in lookup
+		for i := len(parent.Content) - 2; i >= 0; i -= 2 {
+			if parent.Content[i].Value == key {
+				p.cache.replace(parent.Content[i+1], n)
+				return nil
+			}
+		}
+		parent.Content = append(parent.Content, yamlScalar("!!str", key), n)
+		p.cache.forget(parent)
+		return nil
+	case yaml.SequenceNode:
+		i, err := p.index(parent, keys, true)
+		if err != nil {
+			return err
+		}
+		if i == len(parent.Content) {
+			parent.Content = append(parent.Content, n)
+			return nil
+		}
+		p.cache.replace(parent.Content[i], n)
+		return nil
+	default:
+		return errors.Wrapf(ErrWrongType, "cannot set %v: parent is a scalar%s", combine(p.pathToHere, keys), p.at(keys[:len(keys)-1]))
+	}
+}
+
+func (p parsedYAML) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return errors.Wrapf(ErrNotMutable, "cannot delete %v from itself", p.pathToHere)
+	}
+	p.cache.snapshot()
+	parent, err := p.editable(keys[:len(keys)-1], false)
+	if err != nil {
+		return err
+	}
+	key := keys[len(keys)-1]
+	switch parent.Kind {
+	case yaml.MappingNode:
+		content := parent.Content[:0]
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value != key {
+				content = append(content, parent.Content[i], parent.Content[i+1])
+			}
+		}
+		if len(content) == len(parent.Content) {
+			return errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, keys), p.near(keys))
+		}
+		parent.Content = content
+	case yaml.SequenceNode:
+		i, err := p.index(parent, keys, false)
+		if err != nil {
+			return err
+		}
+		parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+	default:
+		return errors.Wrapf(ErrDoesNotExist, "key %v does not exist: parent is a scalar%s", combine(p.pathToHere, keys), p.near(keys))
+	}
+	p.cache.forget(parent)
+	return nil
+}
+
+// editable finds the map or sequence at keys.  If create is true,
+// maps are created for keys that do not exist and nulls are turned
+// into maps.
+func (p parsedYAML) editable(keys []string, create bool) (*yaml.Node, error) {
+	n := p.root
+	for i := 0; ; i++ {
+		for n.Kind == 0 || n.Kind == yaml.DocumentNode || n.Kind == yaml.AliasNode {
+			if n.Kind == yaml.AliasNode {
+				n = n.Alias
+				continue
+			}
+			n.Kind = yaml.DocumentNode
+			if len(n.Content) == 0 {
+				if !create {
+					return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist: empty document", combine(p.pathToHere, keys[:i]))
+				}
+				n.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+			}
+			n = n.Content[0]
+		}
+		if create && n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null" {
+			n.Kind = yaml.MappingNode
+			n.Tag = "!!map"
+			n.Value = ""
+			n.Style = 0
+		}
+		if i == len(keys) {
+			return n, nil
+		}
+		switch n.Kind {
+		case yaml.MappingNode:
+			m, err := p.cache.get(n)
+			if err != nil {
+				return nil, err
+			}
+			child, ok := m[keys[i]]
+			if !ok {
+				if !create {
+					return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, keys[:i+1]), p.near(keys[:i+1]))
+				}
+				child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				n.Content = append(n.Content, yamlScalar("!!str", keys[i]), child)
+				p.cache.forget(n)
+			}
+			n = child
+		case yaml.SequenceNode:
+			j, err := p.index(n, keys[:i+1], false)
+			if err != nil {
+				return nil, err
+			}
+			n = n.Content[j]
+		default:
+			return nil, errors.Wrapf(ErrWrongType, "key %v is a scalar (not a map or array)%s", combine(p.pathToHere, keys[:i]), p.at(keys[:i]))
+		}
+	}
+}
+
+// index parses the last key as an index into a sequence
+func (p parsedYAML) index(seq *yaml.Node, keys []string, appending bool) (int, error) {
+	key := keys[len(keys)-1]
+	if !isNumberRE.MatchString(key) {
+		return 0, errors.Wrapf(ErrWrongType, "cannot use '%s' as an array index for %v", key, combine(p.pathToHere, keys))
+	}
+	i, err := strconv.Atoi(key)
+	if err != nil || i > len(seq.Content) || (i == len(seq.Content) && !appending) {
+		return 0, errors.Wrapf(ErrDoesNotExist, "index %v is out of range (length %d)%s", combine(p.pathToHere, keys), len(seq.Content), p.near(keys))
+	}
+	return i, nil
+}
+
+// yamlValue converts a value for Set into a node
+func yamlValue(value interface{}) (*yaml.Node, error) {
+	if source, ok := value.(Source); ok {
+		return yamlNode(source, nil)
+	}
+	var n yaml.Node
+	err := n.Encode(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode yaml")
+	}
+	return &n, nil
+}
+
+// replace overwrites old with n so that anything referring to old,
+// including aliases, sees the new value.  Comments, the anchor, the
+// position, and the quoting of strings are kept from old.
+func (x *yamlIndex) replace(old *yaml.Node, n *yaml.Node) {
+	if n.HeadComment == "" {
+		n.HeadComment = old.HeadComment
+	}
+	if n.LineComment == "" {
+		n.LineComment = old.LineComment
+	}
+	if n.FootComment == "" {
+		n.FootComment = old.FootComment
+	}
+	if n.Anchor == "" {
+		n.Anchor = old.Anchor
+	}
+	if n.Kind == yaml.ScalarNode && old.Kind == yaml.ScalarNode &&
+		n.Style == 0 && n.ShortTag() == "!!str" && old.ShortTag() == "!!str" {
+		n.Style = old.Style &^ yaml.TaggedStyle
+	}
+	n.Line, n.Column = old.Line, old.Column
+	x.forget(old)
+	*old = *n
+}
+
+// forget drops the index of a map after it is edited
+func (x *yamlIndex) forget(n *yaml.Node) {
+	x.mu.Lock()
+	delete(x.maps, n)
+	x.mu.Unlock()
+}
+
+// jsonConstants are shared by fastjson for every null, true, and
+// false so they must never be overwritten
+var jsonConstants = map[*fastjson.Value]bool{
+	fastjson.MustParse("null"):  true,
+	fastjson.MustParse("true"):  true,
+	fastjson.MustParse("false"): true,
+}
+
+func (p parsedJSON) Set(value interface{}, key ...string) error {
+	nv, err := p.newValue(value)
+	if err != nil {
+		return errors.Wrapf(err, "set %v", combine(p.pathToHere, key))
+	}
+	p.positions.forget()
+	if len(key) == 0 {
+		if jsonConstants[p.value] {
+			return errors.Wrapf(ErrNotMutable, "cannot replace %s at %v in place, set it from its parent", p.value.Type(), p.pathToHere)
+		}
+		*p.value = *nv
+		return nil
+	}
+	parent, err := p.editable(key[:len(key)-1], true)
+	if err != nil {
+		return err
+	}
+	last := key[len(key)-1]
+	switch parent.Type() {
+	case fastjson.TypeObject:
+	case fastjson.TypeArray:
+		i, err := p.index(parent, key, true)
+		if err != nil {
+			return err
+		}
+		if i == len(parent.GetArray()) {
+			parent.SetArrayItem(i, nv)
+			return nil
+		}
+	default:
+		return errors.Wrapf(ErrWrongType, "cannot set %v: parent is a %s%s", combine(p.pathToHere, key), parent.Type(), p.at(key[:len(key)-1]))
+	}
+	if old := parent.Get(last); old != nil && !jsonConstants[old] {
+		// in place so that sources from Recurse see the new value
+		*old = *nv
+		return nil
+	}
+	parent.Set(last, nv)
+	return nil
+}
+
+func (p parsedJSON) Delete(key ...string) error {
+	if len(key) == 0 {
+		return errors.Wrapf(ErrNotMutable, "cannot delete %v from itself", p.pathToHere)
+	}
+	parent, err := p.editable(key[:len(key)-1], false)
+	if err != nil {
+		return err
+	}
+	last := key[len(key)-1]
+	switch parent.Type() {
+	case fastjson.TypeObject:
+		if parent.Get(last) == nil {
+			return errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
+		}
+	case fastjson.TypeArray:
+		_, err := p.index(parent, key, false)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.Wrapf(ErrDoesNotExist, "key %v does not exist: parent is a %s%s", combine(p.pathToHere, key), parent.Type(), p.near(key))
+	}
+	p.positions.forget()
+	parent.Del(last)
+	return nil
+}
+
+// editable finds the object or array at key.  If create is true,
+// objects are created for keys that do not exist and nulls are
+// replaced with objects.
+func (p parsedJSON) editable(key []string, create bool) (*fastjson.Value, error) {
+	v := p.value
+	for i, k := range key {
+		child := v.Get(k)
+		if child == nil || (create && child.Type() == fastjson.TypeNull) {
+			if child == nil && (!create || v.Type() != fastjson.TypeObject) {
+				return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key[:i+1]), p.near(key[:i+1]))
+			}
+			child = p.arena.NewObject()
+			v.Set(k, child)
+		}
+		v = child
+	}
+	return v, nil
+}
+
+// index parses the last key as an index into an array
+func (p parsedJSON) index(array *fastjson.Value, key []string, appending bool) (int, error) {
+	k := key[len(key)-1]
+	length := len(array.GetArray())
+	if !isNumberRE.MatchString(k) {
+		return 0, errors.Wrapf(ErrWrongType, "cannot use '%s' as an array index for %v", k, combine(p.pathToHere, key))
+	}
+	i, err := strconv.Atoi(k)
+	if err != nil || i > length || (i == length && !appending) {
+		return 0, errors.Wrapf(ErrDoesNotExist, "index %v is out of range (length %d)%s", combine(p.pathToHere, key), length, p.near(key))
+	}
+	return i, nil
+}
+
+// newValue converts a value for Set into a fastjson.Value
+func (p parsedJSON) newValue(value interface{}) (*fastjson.Value, error) {
+	var data []byte
+	var err error
+	switch v := value.(type) {
+	case nil:
+		return p.arena.NewNull(), nil
+	case bool:
+		if v {
+			return p.arena.NewTrue(), nil
+		}
+		return p.arena.NewFalse(), nil
+	case string:
+		nv := p.arena.NewString(v)
+		unescapeKeys(nv)
+		return nv, nil
+	case int:
+		return p.arena.NewNumberInt(v), nil
+	case int64:
+		return p.arena.NewNumberString(strconv.FormatInt(v, 10)), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.Errorf("%v cannot be represented in JSON", v)
+		}
+		return p.arena.NewNumberString(formatFloat(v)), nil
+	case Source:
+		data, err = MarshalJSON(v)
+	default:
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "encode json")
+	}
+	var parser fastjson.Parser
+	nv, err := parser.ParseBytes(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "json")
+	}
+	unescapeKeys(nv)
+	return nv, nil
+}
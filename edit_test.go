@@ -0,0 +1,92 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func edit(t *testing.T, source Source) {
+	m, ok := source.(MutableSource)
+	require.True(t, ok, "mutable")
+	require.NoError(t, m.Set("1.2.4", "version"))
+	require.NoError(t, m.Set(true, "flags", "beta"))
+	require.NoError(t, m.Set("c", "features", "2"))
+	require.NoError(t, m.Delete("features", "0"))
+	require.NoError(t, m.Set(map[string]int{"x": 3}, "empty"))
+	require.NoError(t, m.Delete("name"))
+
+	err := m.Set(1, "version", "major")
+	assert.True(t, errors.Is(err, ErrWrongType), "set under scalar %v", err)
+	err = m.Set(1, "features", "5")
+	assert.True(t, errors.Is(err, ErrDoesNotExist), "set past end %v", err)
+	err = m.Delete("missing")
+	assert.True(t, errors.Is(err, ErrDoesNotExist), "delete missing %v", err)
+	err = m.Delete()
+	assert.True(t, errors.Is(err, ErrNotMutable), "delete self %v", err)
+
+	features := source.Recurse("features")
+	require.NoError(t, features.(MutableSource).Set("d", "0"))
+	assert.Equal(t, "d", getString(t, source, "features", "0"))
+
+	wrapped := NewPrefixSource(WithLabel(source, "doc"), "top")
+	require.NoError(t, wrapped.(MutableSource).Set(int64(9), "top", "empty", "y"))
+	err = wrapped.(MutableSource).Set(1, "other")
+	assert.True(t, errors.Is(err, ErrNotMutable), "outside prefix %v", err)
+
+	got, err := MarshalJSON(source)
+	require.NoError(t, err)
+	assert.Equal(t, `{"version":"1.2.4","features":["d","c"],"empty":{"x":3,"y":9},"flags":{"beta":true}}`, string(got))
+}
+
+func TestEditYAML(t *testing.T) {
+	source, err := UnmarshalYAML([]byte(`# settings
+version: "1.2.3" # bump me
+name: svc
+features:
+  - a
+  - b
+empty:
+`))
+	require.NoError(t, err)
+	edit(t, source)
+
+	out, err := MarshalYAML(source)
+	require.NoError(t, err)
+	assert.Equal(t, `# settings
+version: "1.2.4" # bump me
+features:
+  - d
+  - c
+empty:
+  x: 3
+  y: 9
+flags:
+  beta: true
+`, string(out))
+}
+
+func TestEditJSON(t *testing.T) {
+	source, err := UnmarshalJSON([]byte(`{"version":"1.2.3","name":"svc","features":["a","b"],"empty":null}`))
+	require.NoError(t, err)
+	edit(t, source)
+
+	m := source.(MutableSource)
+	require.NoError(t, m.Set(NewPrefixSource(m.Recurse("flags"), "copy"), "more"))
+	require.NoError(t, m.Set([]interface{}{1.5, nil, "s"}, "list"))
+	got, err := MarshalJSON(source.Recurse("more"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"copy":{"beta":true}}`, string(got))
+	assert.Equal(t, Float, source.Type("list", "0"))
+	assert.Equal(t, Nil, source.Type("list", "1"))
+	assert.Equal(t, "s", getString(t, source, "list", "2"))
+
+	require.NoError(t, m.Set("replaced", "list", "1"), "replace null")
+	assert.Equal(t, "replaced", getString(t, source, "list", "1"))
+	null, err := UnmarshalJSON([]byte(`null`))
+	require.NoError(t, err)
+	err = null.(MutableSource).Set(1)
+	assert.True(t, errors.Is(err, ErrNotMutable), "replace null root %v", err)
+}
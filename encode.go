@@ -0,0 +1,306 @@
+package nflex
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalJSON encodes any Source as compact JSON.  Int and Float
+// values stay distinct: a Float that has an integral value is
+// written with a trailing ".0".
+func MarshalJSON(source Source) ([]byte, error) {
+	var buf bytes.Buffer
+	err := EncodeJSON(&buf, source)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// EncodeJSON writes any Source as compact JSON followed by a
+// newline.  The Source is walked as it is written so large
+// documents are not built in memory first.
+func EncodeJSON(w io.Writer, source Source) error {
+	if source == nil {
+		return errors.Wrap(ErrDoesNotExist, "nil source")
+	}
+	bw := bufio.NewWriter(w)
+	err := encodeJSON(bw, source, nil)
+	if err != nil {
+		return err
+	}
+	_ = bw.WriteByte('\n')
+	return errors.Wrap(bw.Flush(), "write json")
+}
+
+func encodeJSON(w *bufio.Writer, source Source, keys []string) error {
+	switch t := source.Type(keys...); t {
+	case Undefined:
+		return errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+	case Nil:
+		_, _ = w.WriteString("null")
+	case Bool:
+		b, err := source.GetBool(keys...)
+		if err != nil {
+			return err
+		}
+		_, _ = w.WriteString(strconv.FormatBool(b))
+	case Int:
+		i, err := source.GetInt(keys...)
+		if err != nil {
+			u, uerr := GetUInt(source, keys...)
+			if uerr != nil {
+				return err
+			}
+			_, _ = w.WriteString(strconv.FormatUint(u, 10))
+			break
+		}
+		_, _ = w.WriteString(strconv.FormatInt(i, 10))
+	case Float:
+		f, err := source.GetFloat(keys...)
+		if err != nil {
+			return err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return errors.Wrapf(ErrWrongType, "key %v is %v which cannot be represented in JSON", keys, f)
+		}
+		_, _ = w.WriteString(formatFloat(f))
+	case String:
+		s, err := source.GetString(keys...)
+		if err != nil {
+			return err
+		}
+		writeJSONString(w, s)
+	case Map:
+		mk, err := source.Keys(keys...)
+		if err != nil {
+			return err
+		}
+		_ = w.WriteByte('{')
+		for i, k := range mk {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			writeJSONString(w, k)
+			_ = w.WriteByte(':')
+			err := encodeJSON(w, source, combine(keys, []string{k}))
+			if err != nil {
+				return err
+			}
+		}
+		_ = w.WriteByte('}')
+	case Slice:
+		length, err := source.Len(keys...)
+		if err != nil {
+			return err
+		}
+		_ = w.WriteByte('[')
+		for i := 0; i < length; i++ {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			err := encodeJSON(w, source, combine(keys, []string{strconv.Itoa(i)}))
+			if err != nil {
+				return err
+			}
+		}
+		_ = w.WriteByte(']')
+	default:
+		return errors.Errorf("key %v has unknown type %s", keys, t)
+	}
+	return nil
+}
+
+// formatFloat renders a float so that it will not be mistaken for
+// an integer when read back
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+const hex = "0123456789abcdef"
+
+func writeJSONString(w *bufio.Writer, s string) {
+	_ = w.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				_ = w.WriteByte('\\')
+				_ = w.WriteByte(c)
+			case c == '\n':
+				_, _ = w.WriteString(`\n`)
+			case c == '\r':
+				_, _ = w.WriteString(`\r`)
+			case c == '\t':
+				_, _ = w.WriteString(`\t`)
+			case c < 0x20:
+				_, _ = w.WriteString(`\u00`)
+				_ = w.WriteByte(hex[c>>4])
+				_ = w.WriteByte(hex[c&0xf])
+			default:
+				_ = w.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			_, _ = w.WriteString("\ufffd")
+		} else {
+			_, _ = w.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	_ = w.WriteByte('"')
+}
+
+// MarshalYAML encodes any Source as YAML.  Strings that would
+// otherwise be read back as some other type are quoted.
+//
+// A document parsed with UnmarshalYAML is written back as the text
+// that was parsed: only values changed with Set or Delete are
+// rewritten, so comments, key order, anchors, and quoting are kept.
+func MarshalYAML(source Source) ([]byte, error) {
+	var buf bytes.Buffer
+	err := EncodeYAML(&buf, source)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeYAML writes any Source as YAML
+func EncodeYAML(w io.Writer, source Source) error {
+	if source == nil {
+		return errors.Wrap(ErrDoesNotExist, "nil source")
+	}
+	if p, ok := source.(parsedYAML); ok && p.root == p.cache.doc {
+		text, err := p.cache.marshal()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(text)
+		return errors.Wrap(err, "write yaml")
+	}
+	node, err := yamlNode(source, nil)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	err = enc.Encode(node)
+	if err != nil {
+		return errors.Wrap(err, "write yaml")
+	}
+	return errors.Wrap(enc.Close(), "write yaml")
+}
+
+func yamlNode(source Source, keys []string) (*yaml.Node, error) {
+	switch t := source.Type(keys...); t {
+	case Undefined:
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+	case Nil:
+		return yamlScalar("!!null", "null"), nil
+	case Bool:
+		b, err := source.GetBool(keys...)
+		if err != nil {
+			return nil, err
+		}
+		return yamlScalar("!!bool", strconv.FormatBool(b)), nil
+	case Int:
+		i, err := source.GetInt(keys...)
+		if err != nil {
+			u, uerr := GetUInt(source, keys...)
+			if uerr != nil {
+				return nil, err
+			}
+			return yamlScalar("!!int", strconv.FormatUint(u, 10)), nil
+		}
+		return yamlScalar("!!int", strconv.FormatInt(i, 10)), nil
+	case Float:
+		f, err := source.GetFloat(keys...)
+		if err != nil {
+			return nil, err
+		}
+		var s string
+		switch {
+		case math.IsNaN(f):
+			s = ".nan"
+		case math.IsInf(f, 1):
+			s = ".inf"
+		case math.IsInf(f, -1):
+			s = "-.inf"
+		default:
+			s = formatFloat(f)
+		}
+		return yamlScalar("!!float", s), nil
+	case String:
+		s, err := source.GetString(keys...)
+		if err != nil {
+			return nil, err
+		}
+		n := yamlScalar("!!str", s)
+		if scalarType(s) != String {
+			n.Style = yaml.DoubleQuotedStyle
+		}
+		return n, nil
+	case Map:
+		mk, err := source.Keys(keys...)
+		if err != nil {
+			return nil, err
+		}
+		n := &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Tag:     "!!map",
+			Content: make([]*yaml.Node, 0, 2*len(mk)),
+		}
+		for _, k := range mk {
+			e, err := yamlNode(source, combine(keys, []string{k}))
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, yamlScalar("!!str", k), e)
+		}
+		return n, nil
+	case Slice:
+		length, err := source.Len(keys...)
+		if err != nil {
+			return nil, err
+		}
+		n := &yaml.Node{
+			Kind:    yaml.SequenceNode,
+			Tag:     "!!seq",
+			Content: make([]*yaml.Node, length),
+		}
+		for i := range n.Content {
+			n.Content[i], err = yamlNode(source, combine(keys, []string{strconv.Itoa(i)}))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	default:
+		return nil, errors.Errorf("key %v has unknown type %s", keys, t)
+	}
+}
+
+func yamlScalar(tag string, value string) *yaml.Node {
+	return &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   tag,
+		Value: value,
+	}
+}
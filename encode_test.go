@@ -0,0 +1,80 @@
+package nflex
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+	got, err := MarshalJSON(NewMultiSource(j, y))
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"a":{"b":{"i":28,"f":34.39,"s":"foo","bt":true,"bf":false,"a":[3,11,3,11],"m":{"k1":"v1","k2":"v2"},"d":{"j":"json","y":"yaml"}}}}`,
+		string(got))
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJSON(&buf, j.Recurse("a", "b", "m")))
+	assert.Equal(t, "{\"k1\":\"v1\",\"k2\":\"v2\"}\n", buf.String())
+}
+
+func TestMarshalYAML(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	got, err := MarshalYAML(j.Recurse("a", "b"))
+	require.NoError(t, err)
+	assert.Equal(t, `i: 28
+f: 34.39
+s: foo
+bt: true
+bf: false
+a:
+  - 3
+  - 11
+m:
+  k1: v1
+  k2: v2
+d:
+  j: json
+`, string(got))
+}
+
+func TestMarshalFaithful(t *testing.T) {
+	s, err := UnmarshalJSON([]byte(`{"f":1.0,"i":1,"s":"1","t":"true","n":null,"e":{},"l":[],"q":"a\"b\n\u0001é"}`))
+	require.NoError(t, err)
+
+	j, err := MarshalJSON(s)
+	require.NoError(t, err)
+	assert.Equal(t, `{"f":1.0,"i":1,"s":"1","t":"true","n":null,"e":{},"l":[],"q":"a\"b\n\u0001é"}`, string(j))
+
+	y, err := MarshalYAML(s)
+	require.NoError(t, err)
+	assert.Equal(t, `f: 1.0
+i: 1
+s: "1"
+t: "true"
+n: null
+e: {}
+l: []
+q: "a\"b\n\x01é"
+`, string(y))
+
+	back, err := UnmarshalYAML(y)
+	require.NoError(t, err)
+	assert.Equal(t, Float, back.Type("f"))
+	assert.Equal(t, Int, back.Type("i"))
+	again, err := MarshalJSON(back)
+	require.NoError(t, err)
+	assert.Contains(t, string(again), `"f":1.0,"i":1,`)
+
+	_, err = MarshalJSON(s.Recurse("nope"))
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+	_, err = MarshalYAML(s.Recurse("nope"))
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+}
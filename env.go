@@ -0,0 +1,73 @@
+package nflex
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+type envOpts struct {
+	environ []string
+	mapKey  func(string) string
+}
+
+type EnvSourceArg func(*envOpts)
+
+// WithEnviron provides the environment variables to use instead
+// of os.Environ().  Each entry is in "key=value" form.
+func WithEnviron(environ []string) EnvSourceArg {
+	return func(o *envOpts) {
+		o.environ = environ
+	}
+}
+
+// WithEnvKeyMapper overrides how each path element is derived
+// from the variable name.  The default is strings.ToLower.
+func WithEnvKeyMapper(mapKey func(string) string) EnvSourceArg {
+	return func(o *envOpts) {
+		o.mapKey = mapKey
+	}
+}
+
+// NewEnvSource creates a source from environment variables.  Only
+// variables whose name starts with prefix are included.  The rest
+// of the name is split on separator to form a path so that with
+// a prefix of "APP_" and a separator of "__", APP_DB__HOST
+// becomes ["db", "host"].  Numeric path elements make slices:
+// APP_SERVERS__0__NAME is the name of the first server.  An empty
+// separator means no nesting: each variable is one top-level key.
+//
+// Values are typed the same way that YAML scalars are: "8080" is
+// an Int and "true" is a Bool.  The source is labeled "environment".
+func NewEnvSource(prefix string, separator string, args ...EnvSourceArg) Source {
+	opts := envOpts{
+		mapKey: strings.ToLower,
+	}
+	for _, f := range args {
+		f(&opts)
+	}
+	if opts.environ == nil {
+		opts.environ = os.Environ()
+	}
+	environ := make([]string, len(opts.environ))
+	copy(environ, opts.environ)
+	sort.Strings(environ)
+	tree := &pathTree{}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		path := []string{name[len(prefix):]}
+		if separator != "" {
+			path = strings.Split(path[0], separator)
+		}
+		for i, p := range path {
+			path[i] = opts.mapKey(p)
+		}
+		tree.add(path, stringLeaf(value))
+	}
+	s := newTreeSource(tree, "environment")
+	debug("nflex/NewEnvSource", s.debugID, s.debugKeys)
+	return s
+}
@@ -0,0 +1,78 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEnviron = []string{
+	"APP_DB__HOST=localhost",
+	"APP_DB__PORT=5432",
+	"APP_DB__RATIO=0.5",
+	"APP_DEBUG=true",
+	"APP_SERVERS__0__NAME=alpha",
+	"APP_SERVERS__1__NAME=beta",
+	"APP_QQ__0=x",
+	"OTHER_THING=ignored",
+	"APP_=ignored",
+}
+
+func TestEnv(t *testing.T) {
+	s := NewEnvSource("APP_", "__", WithEnviron(testEnviron))
+	assert.ElementsMatch(t, []string{"db", "debug", "servers", "qq"}, mustKeys(t, s))
+	assert.Equal(t, Map, s.Type("db"))
+	assert.Equal(t, String, s.Type("db", "host"))
+	assert.Equal(t, Int, s.Type("db", "port"))
+	assert.Equal(t, Float, s.Type("db", "ratio"))
+	assert.Equal(t, Bool, s.Type("debug"))
+	assert.Equal(t, Slice, s.Type("servers"))
+	assert.Equal(t, Undefined, s.Type("thing"))
+	assert.False(t, s.Exists("other"))
+
+	assert.Equal(t, "localhost", getString(t, s, "db", "host"))
+	i, err := s.GetInt("db", "port")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5432), i)
+	b, err := s.GetBool("debug")
+	require.NoError(t, err)
+	assert.True(t, b)
+	_, err = s.GetInt("db", "host")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.GetString("db")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.GetString("db", "user")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+	_, err = s.Keys("servers")
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	assert.Equal(t, 2, getLen(t, s, "servers"))
+	assert.Equal(t, "beta", getString(t, s.Recurse("servers", "1"), "name"))
+	assert.Nil(t, s.Recurse("servers", "2"))
+}
+
+func TestEnvKeyMapper(t *testing.T) {
+	s := NewEnvSource("APP.", ".", WithEnviron([]string{"APP.Db.Host=h"}), WithEnvKeyMapper(func(k string) string { return k }))
+	assert.Equal(t, "h", getString(t, s, "Db", "Host"))
+}
+
+func TestEnvNoSeparator(t *testing.T) {
+	s := NewEnvSource("APP_", "", WithEnviron([]string{"APP_DB__HOST=h", "APP_PORT=80"}))
+	assert.Equal(t, []string{"db__host", "port"}, mustKeys(t, s))
+	assert.Equal(t, "h", getString(t, s, "db__host"))
+}
+
+func TestEnvMulti(t *testing.T) {
+	y, err := UnmarshalFile("rdata1.yaml", WithFS(content))
+	require.NoError(t, err, "open rdata1.yaml")
+	s := NewMultiSource(NewEnvSource("APP_", "__", WithEnviron(testEnviron)), y)
+	assert.Equal(t, 1, getLen(t, s, "qq"))
+	assert.Equal(t, 3, getLen(t, s, "QQ"))
+	s = NewMultiSource(NewEnvSource("APP_", "__", WithEnviron(testEnviron), WithEnvKeyMapper(func(k string) string { return k })), y)
+	assert.Equal(t, 4, getLen(t, s, "QQ"))
+	assert.Equal(t, "x", getString(t, s, "QQ", "0"))
+	assert.Equal(t, "c", getString(t, s, "QQ", "3"))
+	assert.Equal(t, "c", getString(t, s.Recurse("QQ"), "3"))
+	assert.Equal(t, "5432", getString(t, s, "DB", "PORT"))
+}
@@ -0,0 +1,140 @@
+package nflex
+
+import (
+	"flag"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NewFlagSource creates a source from the flags that were explicitly
+// set on a parsed FlagSet.  Flags that were not set are not present
+// so that the source can be layered over other sources with
+// NewMultiSource.  Dots in flag names make nested paths: --db.pool.size
+// is ["db", "pool", "size"].
+//
+// Flag values that implement flag.Getter are typed by the value that
+// Get returns.  Other flag values are typed the same way that YAML
+// scalars are.  The source is labeled "flags".
+func NewFlagSource(fs *flag.FlagSet) Source {
+	tree := &pathTree{}
+	fs.Visit(func(f *flag.Flag) {
+		tree.add(strings.Split(f.Name, "."), flagLeaf{flag: f})
+	})
+	s := newTreeSource(tree, "flags")
+	debug("nflex/NewFlagSource", s.debugID, s.debugKeys)
+	return s
+}
+
+type flagLeaf struct {
+	flag *flag.Flag
+}
+
+func (l flagLeaf) get() interface{} {
+	if g, ok := l.flag.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+	return nil
+}
+
+func (l flagLeaf) Type() NodeType {
+	switch l.get().(type) {
+	case bool:
+		return Bool
+	case int, int64, uint, uint64:
+		return Int
+	case float64:
+		return Float
+	case string:
+		return String
+	default:
+		return scalarType(l.flag.Value.String())
+	}
+}
+
+func (l flagLeaf) Bool() (bool, error) {
+	switch v := l.get().(type) {
+	case bool:
+		return v, nil
+	case nil, string:
+		return strconv.ParseBool(l.flag.Value.String())
+	default:
+		return false, errors.Errorf("flag -%s is a %T", l.flag.Name, v)
+	}
+}
+
+func (l flagLeaf) Int() (int64, error) {
+	switch v := l.get().(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return l.checkInt(uint64(v))
+	case uint64:
+		return l.checkInt(v)
+	case nil, string:
+		return parseInt(l.flag.Value.String())
+	default:
+		return 0, errors.Errorf("flag -%s is a %T", l.flag.Name, v)
+	}
+}
+
+func (l flagLeaf) checkInt(u uint64) (int64, error) {
+	if u > math.MaxInt64 {
+		return 0, errors.Errorf("flag -%s value %d overflows int64", l.flag.Name, u)
+	}
+	return int64(u), nil
+}
+
+func (l flagLeaf) UInt() (uint64, error) {
+	switch v := l.get().(type) {
+	case uint:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case int:
+		return l.checkUInt(int64(v))
+	case int64:
+		return l.checkUInt(v)
+	case nil, string:
+		return parseUInt(l.flag.Value.String())
+	default:
+		return 0, errors.Errorf("flag -%s is a %T", l.flag.Name, v)
+	}
+}
+
+func (l flagLeaf) checkUInt(i int64) (uint64, error) {
+	if i < 0 {
+		return 0, errors.Errorf("flag -%s value %d is negative", l.flag.Name, i)
+	}
+	return uint64(i), nil
+}
+
+func (l flagLeaf) Float() (float64, error) {
+	switch v := l.get().(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case nil, string:
+		return parseFloat(l.flag.Value.String())
+	default:
+		return 0, errors.Errorf("flag -%s is a %T", l.flag.Name, v)
+	}
+}
+
+func (l flagLeaf) String() string {
+	if s, ok := l.get().(string); ok {
+		return s
+	}
+	return l.flag.Value.String()
+}
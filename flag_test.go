@@ -0,0 +1,93 @@
+package nflex
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type plainValue string
+
+func (p *plainValue) String() string     { return string(*p) }
+func (p *plainValue) Set(s string) error { *p = plainValue(s); return nil }
+
+func TestFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("db.pool.size", 5, "pool size")
+	fs.String("db.host", "localhost", "host")
+	fs.Bool("debug", false, "debug")
+	fs.Float64("ratio", 0, "ratio")
+	fs.Uint64("big", 0, "big")
+	fs.Duration("timeout", time.Second, "timeout")
+	fs.String("name", "", "name")
+	var pv plainValue
+	fs.Var(&pv, "plain", "not a getter")
+	require.NoError(t, fs.Parse([]string{
+		"--db.pool.size=10",
+		"--debug",
+		"--ratio=2.5",
+		"--big=18446744073709551615",
+		"--timeout=30s",
+		"--name=0123",
+		"--plain=42",
+	}))
+	s := NewFlagSource(fs)
+
+	assert.Equal(t, []string{"big", "db", "debug", "name", "plain", "ratio", "timeout"}, mustKeys(t, s))
+	assert.Equal(t, []string{"pool"}, mustKeys(t, s, "db"), "unset flags are not present")
+	assert.False(t, s.Exists("db", "host"))
+
+	assert.Equal(t, Int, s.Type("db", "pool", "size"))
+	i, err := s.GetInt("db", "pool", "size")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), i)
+
+	assert.Equal(t, Bool, s.Type("debug"))
+	b, err := s.GetBool("debug")
+	require.NoError(t, err)
+	assert.True(t, b)
+
+	assert.Equal(t, Float, s.Type("ratio"))
+	f, err := s.GetFloat("ratio")
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, f)
+	_, err = s.GetInt("ratio")
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	_, err = s.GetInt("big")
+	assert.ErrorIs(t, err, ErrWrongType)
+	u, err := GetUInt(s, "big")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(18446744073709551615), u)
+
+	assert.Equal(t, String, s.Type("name"), "string flags are strings even if they look numeric")
+	assert.Equal(t, "0123", getString(t, s, "name"))
+	assert.Equal(t, "30s", getString(t, s, "timeout"))
+
+	assert.Equal(t, Int, s.Type("plain"))
+	i, err = s.GetInt("plain")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), i)
+
+	_, err = s.GetString("db")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.GetString("nope")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+}
+
+func TestFlagsMulti(t *testing.T) {
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("a.b.s", "", "s")
+	fs.Int("a.b.i", 0, "i")
+	require.NoError(t, fs.Parse([]string{"--a.b.s=bar"}))
+	s := NewMultiSource(NewFlagSource(fs), y)
+	assert.Equal(t, "bar", getString(t, s, "a", "b", "s"))
+	i, err := s.GetInt("a", "b", "i")
+	require.NoError(t, err)
+	assert.Equal(t, int64(28), i)
+}
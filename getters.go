@@ -0,0 +1,206 @@
+package nflex
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// located is implemented by sources that know where they are in the
+// document that they came from
+type located interface {
+	path() []string
+}
+
+// pathOf is keys as a path from the top of the source's document, for
+// errors
+func pathOf(source Source, keys []string) []string {
+	if l, ok := source.(located); ok {
+		return combine(l.path(), keys)
+	}
+	return keys
+}
+
+// timeGetter is implemented by sources that can hold times that have
+// not been turned into strings, like the datetimes of TOML
+type timeGetter interface {
+	getTime(keys ...string) (time.Time, bool)
+}
+
+func nativeTime(source Source, keys ...string) (time.Time, bool) {
+	if g, ok := source.(timeGetter); ok {
+		return g.getTime(keys...)
+	}
+	return time.Time{}, false
+}
+
+// GetDuration reads a duration, like "30s" or "1h30m", in the format
+// of time.ParseDuration
+func GetDuration(source Source, keys ...string) (time.Duration, error) {
+	s, err := source.GetString(keys...)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Wrapf(ErrWrongType, "key %v: %s%s", pathOf(source, keys), err, positionSuffix(PositionOf(source, keys...)))
+	}
+	return d, nil
+}
+
+// timeLayouts are the timestamp formats of YAML and TOML.  Go's
+// RFC3339 format is the first one.
+var timeLayouts = []string{
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999Z07:00",
+	"2006-1-2T15:4:5.999999999",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+}
+
+// yamlTimeRE is the YAML timestamp format, which allows spaces
+// before the time zone and one digit hours in it, like
+// "2001-12-14 21:59:43.10 -5".  Go layouts cannot describe that.
+var yamlTimeRE = regexp.MustCompile(`^([0-9]{4})-([0-9]{1,2})-([0-9]{1,2})(?:[Tt]|[ \t]+)` +
+	`([0-9]{1,2}):([0-9]{2}):([0-9]{2})(?:\.([0-9]*))?` +
+	`(?:[ \t]*(Z|([-+])([0-9]{1,2})(?::([0-9]{2}))?))?$`)
+
+// parseYAMLTime reads timestamps that match yamlTimeRE
+func parseYAMLTime(s string) (time.Time, bool) {
+	m := yamlTimeRE.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false
+	}
+	n := make([]int, 7)
+	for i := range n {
+		n[i], _ = strconv.Atoi(m[i+1])
+	}
+	if n[1] < 1 || n[1] > 12 || n[2] < 1 || n[2] > 31 || n[3] > 23 || n[4] > 59 || n[5] > 60 {
+		return time.Time{}, false
+	}
+	nanos := 0
+	if fraction := m[7]; fraction != "" {
+		if len(fraction) > 9 {
+			fraction = fraction[:9]
+		}
+		nanos, _ = strconv.Atoi(fraction + strings.Repeat("0", 9-len(fraction)))
+	}
+	zone := time.UTC
+	if m[9] != "" {
+		hours, _ := strconv.Atoi(m[10])
+		minutes, _ := strconv.Atoi(m[11])
+		offset := hours*3600 + minutes*60
+		if m[9] == "-" {
+			offset = -offset
+		}
+		zone = time.FixedZone("", offset)
+	}
+	return time.Date(n[0], time.Month(n[1]), n[2], n[3], n[4], n[5], nanos, zone), true
+}
+
+// GetTime reads a timestamp.  It accepts RFC 3339 timestamps and
+// the other timestamp formats of YAML and TOML, including TOML
+// datetimes.  Timestamps without a time zone are UTC.
+func GetTime(source Source, keys ...string) (time.Time, error) {
+	if t, ok := nativeTime(source, keys...); ok {
+		switch t.Location().String() {
+		case "datetime-local", "date-local":
+			// TOML local datetimes are in the machine's zone
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+		case "time-local":
+			return time.Time{}, errors.Wrapf(ErrWrongType, "key %v is a time of day (not a timestamp)%s",
+				pathOf(source, keys), positionSuffix(PositionOf(source, keys...)))
+		}
+		return t, nil
+	}
+	s, err := source.GetString(keys...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if t, ok := parseYAMLTime(s); ok {
+		return t, nil
+	}
+	return time.Time{}, errors.Wrapf(ErrWrongType, "key %v: cannot parse '%s' as a time%s", pathOf(source, keys), s, positionSuffix(PositionOf(source, keys...)))
+}
+
+var byteSizeRE = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)$`)
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"p":   1e15,
+	"pb":  1e15,
+	"e":   1e18,
+	"eb":  1e18,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+	"pi":  1 << 50,
+	"pib": 1 << 50,
+	"ei":  1 << 60,
+	"eib": 1 << 60,
+}
+
+// GetByteSize reads a number of bytes.  Integers are bytes.  Strings
+// are a number and a unit, like "512", "10MiB", "1.5 GB", or "64k".
+// Units are not case sensitive.  KB, MB, and so on, and K, M, and so
+// on, are powers of 1000.  KiB, MiB, and so on, and Ki, Mi, and so on,
+// are powers of 1024.
+func GetByteSize(source Source, keys ...string) (uint64, error) {
+	if source.Type(keys...) == Int {
+		return GetUInt(source, keys...)
+	}
+	s, err := source.GetString(keys...)
+	if err != nil {
+		return 0, err
+	}
+	fail := func(why string) (uint64, error) {
+		return 0, errors.Wrapf(ErrWrongType, "key %v: cannot parse '%s' as a byte size: %s%s", pathOf(source, keys), s, why, positionSuffix(PositionOf(source, keys...)))
+	}
+	m := byteSizeRE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return fail("not a number and unit")
+	}
+	unit, ok := byteUnits[strings.ToLower(m[2])]
+	if !ok {
+		return fail("unknown unit")
+	}
+	if !strings.Contains(m[1], ".") {
+		u, err := strconv.ParseUint(m[1], 10, 64)
+		if err == nil && (unit == 1 || u <= math.MaxUint64/uint64(unit)) {
+			return u * uint64(unit), nil
+		}
+	}
+	f, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return fail(err.Error())
+	}
+	f *= unit
+	if f >= math.MaxUint64 {
+		return fail("too large")
+	}
+	return uint64(f), nil
+}
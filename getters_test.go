@@ -0,0 +1,142 @@
+package nflex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gettersYAML = `
+timeout: 30s
+slow: 1h30m
+zero: 0
+bad_timeout: 30
+expires: 2026-01-01T00:00:00Z
+day: 2026-01-02
+offset: 2026-01-01T10:00:00+02:00
+bad_time: tomorrow
+max_body: 10MiB
+cache: 1.5 GB
+plain: 4096
+small: 64k
+bad_size: 10 parsecs
+negative: -1
+spaced: 2001-12-14 21:59:43.10 -5
+spaced_minutes: 2001-12-14 21:59:43.10 -05:00
+spaced_z: 2001-12-14t21:59:43.10 Z
+tabbed: 2001-12-14	21:59:43 +1
+`
+
+func TestGetDuration(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(gettersYAML))
+	require.NoError(t, err)
+	for key, want := range map[string]time.Duration{
+		"timeout": 30 * time.Second,
+		"slow":    90 * time.Minute,
+		"zero":    0,
+	} {
+		d, err := GetDuration(s, key)
+		if assert.NoError(t, err, key) {
+			assert.Equal(t, want, d, key)
+		}
+	}
+	_, err = GetDuration(s, "bad_timeout")
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.Contains(t, err.Error(), "[bad_timeout]")
+	assert.Contains(t, err.Error(), "at 5:14")
+	_, err = GetDuration(s, "missing")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+
+	env := NewEnvSource("APP_", "__", WithEnviron([]string{"APP_HTTP__TIMEOUT=250ms"}))
+	d, err := GetDuration(NewMultiSource(env, s), "http", "timeout")
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, d)
+}
+
+func TestGetTime(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(gettersYAML))
+	require.NoError(t, err)
+	for key, want := range map[string]time.Time{
+		"expires": time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		"day":     time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		"offset":  time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC),
+		// examples from the YAML timestamp spec
+		"spaced":         time.Date(2001, 12, 15, 2, 59, 43, 100000000, time.UTC),
+		"spaced_minutes": time.Date(2001, 12, 15, 2, 59, 43, 100000000, time.UTC),
+		"spaced_z":       time.Date(2001, 12, 14, 21, 59, 43, 100000000, time.UTC),
+		"tabbed":         time.Date(2001, 12, 14, 20, 59, 43, 0, time.UTC),
+	} {
+		got, err := GetTime(s, key)
+		if assert.NoError(t, err, key) {
+			assert.True(t, want.Equal(got), "%s: %s", key, got)
+		}
+	}
+	_, err = GetTime(s, "bad_time")
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	toml, err := UnmarshalTOML([]byte(tomlDoc))
+	require.NoError(t, err)
+	when, err := GetTime(WithLabel(toml, "doc.toml"), "when")
+	require.NoError(t, err)
+	assert.True(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Equal(when), when)
+	day, err := GetTime(toml, "day")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01-02", day.Format("2006-01-02"))
+	_, err = GetTime(toml, "clock")
+	assert.ErrorIs(t, err, ErrWrongType, "a time of day is not a timestamp")
+
+	local, err := UnmarshalTOML([]byte("[at]\nstart = 2026-01-02T03:04:05.5\nday = 2026-01-02\n"))
+	require.NoError(t, err)
+	merged := NewMultiSource(WithLabel(NewPrefixSource(local.Recurse("at"), "x"), "local.toml"))
+	start, err := GetTime(merged, "x", "start")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 5e8, time.UTC), start)
+	day, err = GetTime(local.Recurse("at"), "day")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), day)
+}
+
+func TestGetterErrorPaths(t *testing.T) {
+	s, err := UnmarshalYAML([]byte("a:\n  b:\n    d: soon\n    t: later\n    n: lots\n"))
+	require.NoError(t, err)
+	inner := s.Recurse("a")
+	_, err = GetDuration(inner, "b", "d")
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.Contains(t, err.Error(), "key [a b d]")
+	_, err = GetTime(WithLabel(inner, "x.yaml"), "b", "t")
+	assert.Contains(t, err.Error(), "key [a b t]")
+	_, err = GetByteSize(NewMultiSource(s).Recurse("a", "b"), "n")
+	assert.Contains(t, err.Error(), "key [a b n]")
+}
+
+func TestGetByteSize(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(gettersYAML))
+	require.NoError(t, err)
+	for key, want := range map[string]uint64{
+		"max_body": 10 << 20,
+		"cache":    1500000000,
+		"plain":    4096,
+		"small":    64000,
+	} {
+		got, err := GetByteSize(NewPrefixSource(s, "limits"), "limits", key)
+		if assert.NoError(t, err, key) {
+			assert.Equal(t, want, got, key)
+		}
+	}
+	for _, key := range []string{"bad_size", "negative", "timeout"} {
+		_, err := GetByteSize(s, key)
+		assert.ErrorIs(t, err, ErrWrongType, key)
+	}
+
+	j, err := UnmarshalJSON([]byte(`{"huge":"20EiB","max":"16EiB","exact":"15EiB"}`))
+	require.NoError(t, err)
+	_, err = GetByteSize(j, "huge")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = GetByteSize(j, "max")
+	assert.ErrorIs(t, err, ErrWrongType)
+	exact, err := GetByteSize(j, "exact")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(15<<60), exact)
+}
@@ -3,6 +3,7 @@ module github.com/muir/nflex
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.11.1
 	github.com/valyala/fastjson v1.6.7
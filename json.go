@@ -1,8 +1,6 @@
 package nflex
 
 import (
-	"strconv"
-
 	"github.com/pkg/errors"
 	"github.com/valyala/fastjson"
 )
@@ -11,6 +9,9 @@ type parsedJSON struct {
 	debugID    int
 	value      *fastjson.Value
 	pathToHere []string
+	label      string
+	positions  *jsonPositions
+	arena      *fastjson.Arena
 }
 
 func UnmarshalJSON(data []byte) (Source, error) {
@@ -19,12 +20,32 @@ func UnmarshalJSON(data []byte) (Source, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "json")
 	}
+	unescapeKeys(value)
 	return parsedJSON{
-		debugID: debugID(),
-		value:   value,
+		debugID:   debugID(),
+		value:     value,
+		positions: newJSONPositions(data),
+		arena:     &fastjson.Arena{},
 	}, nil
 }
 
+// unescapeKeys makes fastjson unescape every object key now.  It
+// otherwise does that lazily, which makes reads unsafe to do from
+// more than one goroutine.
+func unescapeKeys(v *fastjson.Value) {
+	switch v.Type() {
+	case fastjson.TypeObject:
+		o, _ := v.Object()
+		o.Visit(func(_ []byte, e *fastjson.Value) {
+			unescapeKeys(e)
+		})
+	case fastjson.TypeArray:
+		for _, e := range v.GetArray() {
+			unescapeKeys(e)
+		}
+	}
+}
+
 func (p parsedJSON) Exists(key ...string) bool {
 	v := p.value.Get(key...)
 	return v != nil
@@ -44,15 +65,50 @@ func (p parsedJSON) Recurse(key ...string) Source {
 		value:      v,
 		pathToHere: combine(p.pathToHere, key),
 		debugID:    debugID(),
+		label:      p.label,
+		positions:  p.positions,
+		arena:      p.arena,
 	}
 	debug("nflex/json: Recurse(", key, ")", id(p), "->", id(n))
 	return n
 }
 
+func (p parsedJSON) Position(key ...string) (string, int, int, bool) {
+	if p.value.Get(key...) == nil {
+		return "", 0, 0, false
+	}
+	line, col, ok := p.positions.lookup(combine(p.pathToHere, key))
+	return p.label, line, col, ok
+}
+
+func (p parsedJSON) keyPosition(key ...string) (string, int, int, bool) {
+	if p.value.Get(key...) == nil {
+		return "", 0, 0, false
+	}
+	line, col, ok := p.positions.lookupKey(combine(p.pathToHere, key))
+	return p.label, line, col, ok
+}
+
+func (p parsedJSON) at(key []string) string {
+	return positionSuffix(p.Position(key...))
+}
+
+func (p parsedJSON) near(key []string) string {
+	return nearSuffix(p, key)
+}
+
+func (p parsedJSON) Label() string  { return p.label }
+func (p parsedJSON) path() []string { return p.pathToHere }
+
+func (p parsedJSON) withLabel(label string) Source {
+	p.label = label
+	return p
+}
+
 func (p parsedJSON) GetBool(key ...string) (bool, error) {
 	v := p.value.Get(key...)
 	if v == nil {
-		return false, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, key))
+		return false, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
 	}
 	switch v.Type() {
 	case fastjson.TypeTrue:
@@ -60,78 +116,95 @@ func (p parsedJSON) GetBool(key ...string) (bool, error) {
 	case fastjson.TypeFalse:
 		return false, nil
 	default:
-		return false, errors.Wrapf(ErrWrongType, "key %v is a %s (not a boolean)", combine(p.pathToHere, key), v.Type())
+		return false, errors.Wrapf(ErrWrongType, "key %v is a %s (not a boolean)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	}
 }
 
 func (p parsedJSON) GetInt(key ...string) (int64, error) {
 	v := p.value.Get(key...)
 	if v == nil {
-		return 0, errors.Errorf("key %v does not exist", combine(p.pathToHere, key))
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
 	}
 	switch v.Type() {
 	case fastjson.TypeString:
-		i, err := strconv.ParseInt(string(v.String()), 10, 64)
+		i, err := parseInt(string(v.GetStringBytes()))
 		if err != nil {
-			return 0, errors.Wrapf(ErrWrongType, "parse int '%s' at %v: %s", string(v.String()), combine(p.pathToHere, key), err)
+			return 0, errors.Wrapf(ErrWrongType, "parse int '%s' at %v: %s%s", string(v.GetStringBytes()), combine(p.pathToHere, key), err, p.at(key))
 		}
 		return i, nil
 	case fastjson.TypeNumber:
-		return v.GetInt64(), nil
+		i, err := parseInt(v.String())
+		if err != nil {
+			return 0, errors.Wrapf(ErrWrongType, "key %v: %s%s", combine(p.pathToHere, key), err, p.at(key))
+		}
+		return i, nil
 	default:
-		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a number)", combine(p.pathToHere, key), v.Type())
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a number)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	}
 }
 
 func (p parsedJSON) GetUInt(key ...string) (uint64, error) {
 	v := p.value.Get(key...)
 	if v == nil {
-		return 0, errors.Errorf("key %v does not exist", combine(p.pathToHere, key))
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
 	}
 	switch v.Type() {
 	case fastjson.TypeString:
-		i, err := strconv.ParseUint(string(v.String()), 10, 64)
+		i, err := parseUInt(string(v.GetStringBytes()))
 		if err != nil {
-			return 0, errors.Wrapf(ErrWrongType, "parse int '%s' at %v: %s", string(v.String()), combine(p.pathToHere, key), err)
+			return 0, errors.Wrapf(ErrWrongType, "parse int '%s' at %v: %s%s", string(v.GetStringBytes()), combine(p.pathToHere, key), err, p.at(key))
 		}
 		return i, nil
 	case fastjson.TypeNumber:
-		return v.GetUint64(), nil
+		u, err := parseUInt(v.String())
+		if err != nil {
+			return 0, errors.Wrapf(ErrWrongType, "key %v: %s%s", combine(p.pathToHere, key), err, p.at(key))
+		}
+		return u, nil
 	default:
-		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a number)", combine(p.pathToHere, key), v.Type())
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a number)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	}
 }
 
 func (p parsedJSON) GetFloat(key ...string) (float64, error) {
 	v := p.value.Get(key...)
 	if v == nil {
-		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, key))
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
 	}
 	switch v.Type() {
 	case fastjson.TypeNumber:
-		return v.GetFloat64(), nil
+		f, err := parseFloat(v.String())
+		if err != nil {
+			return 0, errors.Wrapf(ErrWrongType, "key %v: %s%s", combine(p.pathToHere, key), err, p.at(key))
+		}
+		return f, nil
 	default:
-		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a number)", combine(p.pathToHere, key), v.Type())
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a number)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	}
 }
 
 func (p parsedJSON) GetString(key ...string) (string, error) {
 	v := p.value.Get(key...)
 	if v == nil {
-		return "", errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, key))
+		return "", errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
 	}
 	switch v.Type() {
 	case fastjson.TypeString:
 		return string(v.GetStringBytes()), nil
+	case fastjson.TypeNumber:
+		if jsonNumberType(v.String()) == String {
+			return v.String(), nil
+		}
+		return "", errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	default:
-		return "", errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)", combine(p.pathToHere, key), v.Type())
+		return "", errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	}
 }
 
 func (p parsedJSON) Keys(key ...string) ([]string, error) {
 	v := p.value.Get(key...)
 	if v == nil {
-		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, key))
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
 	}
 	switch v.Type() {
 	case fastjson.TypeNull:
@@ -147,14 +220,14 @@ func (p parsedJSON) Keys(key ...string) ([]string, error) {
 		})
 		return keys, nil
 	default:
-		return nil, errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)", combine(p.pathToHere, key), v.Type())
+		return nil, errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	}
 }
 
 func (p parsedJSON) Len(key ...string) (int, error) {
 	v := p.value.Get(key...)
 	if v == nil {
-		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, key))
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist%s", combine(p.pathToHere, key), p.near(key))
 	}
 	switch v.Type() {
 	case fastjson.TypeNull:
@@ -163,7 +236,7 @@ func (p parsedJSON) Len(key ...string) (int, error) {
 		a := v.GetArray()
 		return len(a), nil
 	default:
-		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)", combine(p.pathToHere, key), v.Type())
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)%s", combine(p.pathToHere, key), v.Type(), p.at(key))
 	}
 }
 
@@ -182,10 +255,7 @@ func (p parsedJSON) Type(key ...string) NodeType {
 	case fastjson.TypeString:
 		return String
 	case fastjson.TypeNumber:
-		if intRE.MatchString(v.String()) {
-			return Int
-		}
-		return Float
+		return jsonNumberType(v.String())
 	case fastjson.TypeTrue, fastjson.TypeFalse:
 		return Bool
 	default:
@@ -0,0 +1,196 @@
+package nflex
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// jsonPositions finds the line and column of every value in a JSON
+// document.  fastjson does not track positions so the document is
+// scanned again the first time a position is needed.  The document
+// has already been validated by fastjson.  Once the document is
+// edited, the positions no longer match it so none are reported.
+type jsonPositions struct {
+	once    sync.Once
+	data    []byte
+	byPath  map[string]jsonPosition
+	keys    map[string]jsonPosition // of the keys of map entries
+	mutated atomic.Bool
+}
+
+type jsonPosition struct {
+	line int
+	col  int
+}
+
+func newJSONPositions(data []byte) *jsonPositions {
+	c := make([]byte, len(data))
+	copy(c, data)
+	return &jsonPositions{data: c}
+}
+
+// forget stops positions from being reported after an edit
+func (j *jsonPositions) forget() {
+	j.mutated.Store(true)
+}
+
+func (j *jsonPositions) lookup(path []string) (int, int, bool) {
+	return j.find(path, false)
+}
+
+// lookupKey finds the key of the map entry at path
+func (j *jsonPositions) lookupKey(path []string) (int, int, bool) {
+	return j.find(path, true)
+}
+
+func (j *jsonPositions) find(path []string, key bool) (int, int, bool) {
+	if j.mutated.Load() {
+		return 0, 0, false
+	}
+	j.once.Do(func() {
+		s := jsonScanner{
+			data:   j.data,
+			line:   1,
+			col:    1,
+			byPath: make(map[string]jsonPosition),
+			keys:   make(map[string]jsonPosition),
+		}
+		s.value("")
+		j.byPath = s.byPath
+		j.keys = s.keys
+		j.data = nil
+	})
+	var b strings.Builder
+	for _, p := range path {
+		b.WriteByte(0)
+		b.WriteString(p)
+	}
+	positions := j.byPath
+	if key {
+		positions = j.keys
+	}
+	pos, ok := positions[b.String()]
+	return pos.line, pos.col, ok
+}
+
+type jsonScanner struct {
+	data   []byte
+	i      int
+	line   int
+	col    int
+	byPath map[string]jsonPosition
+	keys   map[string]jsonPosition
+}
+
+func (s *jsonScanner) advance() {
+	if s.data[s.i] == '\n' {
+		s.line++
+		s.col = 1
+	} else if s.data[s.i]&0xC0 != 0x80 {
+		// count runes, not bytes
+		s.col++
+	}
+	s.i++
+}
+
+func (s *jsonScanner) skipWS() {
+	for s.i < len(s.data) {
+		switch s.data[s.i] {
+		case ' ', '\t', '\r', '\n':
+			s.advance()
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) value(path string) {
+	s.skipWS()
+	if s.i >= len(s.data) {
+		return
+	}
+	if _, ok := s.byPath[path]; !ok {
+		// fastjson uses the first of duplicate keys
+		s.byPath[path] = jsonPosition{line: s.line, col: s.col}
+	}
+	switch s.data[s.i] {
+	case '{':
+		s.advance()
+		for s.i < len(s.data) {
+			s.skipWS()
+			if s.i >= len(s.data) || s.data[s.i] == '}' {
+				break
+			}
+			keyPosition := jsonPosition{line: s.line, col: s.col}
+			key := s.str()
+			if _, ok := s.keys[path+"\x00"+key]; !ok {
+				s.keys[path+"\x00"+key] = keyPosition
+			}
+			s.skipWS()
+			if s.i < len(s.data) && s.data[s.i] == ':' {
+				s.advance()
+			}
+			s.value(path + "\x00" + key)
+			s.skipWS()
+			if s.i < len(s.data) && s.data[s.i] == ',' {
+				s.advance()
+			}
+		}
+		if s.i < len(s.data) {
+			s.advance()
+		}
+	case '[':
+		s.advance()
+		for idx := 0; s.i < len(s.data); idx++ {
+			s.skipWS()
+			if s.i >= len(s.data) || s.data[s.i] == ']' {
+				break
+			}
+			s.value(path + "\x00" + strconv.Itoa(idx))
+			s.skipWS()
+			if s.i < len(s.data) && s.data[s.i] == ',' {
+				s.advance()
+			}
+		}
+		if s.i < len(s.data) {
+			s.advance()
+		}
+	case '"':
+		s.str()
+	default:
+		for s.i < len(s.data) && !bytes.ContainsAny(s.data[s.i:s.i+1], ",}] \t\r\n") {
+			s.advance()
+		}
+	}
+}
+
+// str consumes a string and returns its value
+func (s *jsonScanner) str() string {
+	start := s.i
+	s.advance()
+	escaped := false
+	for s.i < len(s.data) {
+		switch s.data[s.i] {
+		case '\\':
+			escaped = true
+			s.advance()
+		case '"':
+			s.advance()
+			raw := s.data[start:s.i]
+			if !escaped {
+				return string(raw[1 : len(raw)-1])
+			}
+			var decoded string
+			_ = json.Unmarshal(raw, &decoded)
+			return decoded
+		}
+		if s.i < len(s.data) {
+			s.advance()
+		}
+	}
+	return ""
+}
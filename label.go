@@ -0,0 +1,142 @@
+package nflex
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Labeled is implemented by sources that know a human-readable name
+// for where their data came from, such as the file that was read.
+type Labeled interface {
+	Source
+	Label() string
+}
+
+// relabeler is implemented by sources that carry their own label
+type relabeler interface {
+	withLabel(label string) Source
+}
+
+var _ CanMutate = labeledSource{}
+
+// labeledSource adds a label to a source that does not carry its own
+type labeledSource struct {
+	source  Source
+	label   string
+	debugID int
+}
+
+// WithLabel attaches a human-readable name to a source.  The label
+// is kept by sources returned from Recurse and is reported by
+// MultiSource.Explain.  UnmarshalFile labels the sources it returns
+// with the file name.
+func WithLabel(source Source, label string) Source {
+	if source == nil {
+		return nil
+	}
+	if r, ok := source.(relabeler); ok {
+		return r.withLabel(label)
+	}
+	return labeledSource{
+		source:  source,
+		label:   label,
+		debugID: debugID(),
+	}
+}
+
+// SourceLabel returns the label of a source or "" if it does not
+// have one
+func SourceLabel(source Source) string {
+	if l, ok := source.(Labeled); ok {
+		return l.Label()
+	}
+	return ""
+}
+
+func (l labeledSource) Label() string { return l.label }
+func (l labeledSource) path() []string {
+	if p, ok := l.source.(located); ok {
+		return p.path()
+	}
+	return nil
+}
+func (l labeledSource) getTime(keys ...string) (time.Time, bool) {
+	return nativeTime(l.source, keys...)
+}
+
+func (l labeledSource) Position(keys ...string) (string, int, int, bool) {
+	file, line, col, ok := PositionOf(l.source, keys...)
+	if ok && file == "" {
+		file = l.label
+	}
+	return file, line, col, ok
+}
+
+func (l labeledSource) keyPosition(keys ...string) (string, int, int, bool) {
+	file, line, col, ok := keyPositionOf(l.source, keys...)
+	if ok && file == "" {
+		file = l.label
+	}
+	return file, line, col, ok
+}
+
+func (l labeledSource) Tag(keys ...string) string { return TagOf(l.source, keys...) }
+
+func (l labeledSource) Mutate(mutation Mutation) Source {
+	n := labeledSource{
+		source:  mutation.Apply(l.source),
+		label:   l.label,
+		debugID: debugID(),
+	}
+	debug("nflex/label Mutate", id(l), "->", id(n))
+	return n
+}
+
+func (l labeledSource) Recurse(keys ...string) Source {
+	if len(keys) == 0 {
+		return l
+	}
+	r := l.source.Recurse(keys...)
+	if r == nil {
+		debug("nflex/label Recurse(", keys, ")", id(l), "-> nil")
+		return nil
+	}
+	n := WithLabel(r, l.label)
+	debug("nflex/label Recurse(", keys, ")", id(l), "->", id(n))
+	return n
+}
+
+func (l labeledSource) Exists(keys ...string) bool { return l.source.Exists(keys...) }
+func (l labeledSource) GetBool(keys ...string) (bool, error) {
+	return l.source.GetBool(keys...)
+}
+func (l labeledSource) GetInt(keys ...string) (int64, error) {
+	return l.source.GetInt(keys...)
+}
+func (l labeledSource) GetUInt(keys ...string) (uint64, error) {
+	return GetUInt(l.source, keys...)
+}
+func (l labeledSource) GetFloat(keys ...string) (float64, error) {
+	return l.source.GetFloat(keys...)
+}
+func (l labeledSource) GetString(keys ...string) (string, error) {
+	return l.source.GetString(keys...)
+}
+func (l labeledSource) Keys(keys ...string) ([]string, error) { return l.source.Keys(keys...) }
+func (l labeledSource) Len(keys ...string) (int, error)       { return l.source.Len(keys...) }
+func (l labeledSource) Type(keys ...string) NodeType          { return l.source.Type(keys...) }
+
+func (l labeledSource) Set(value interface{}, keys ...string) error {
+	if m, ok := l.source.(MutableSource); ok {
+		return m.Set(value, keys...)
+	}
+	return errors.Wrapf(ErrNotMutable, "source %s", l.label)
+}
+
+func (l labeledSource) Delete(keys ...string) error {
+	if m, ok := l.source.(MutableSource); ok {
+		return m.Delete(keys...)
+	}
+	return errors.Wrapf(ErrNotMutable, "source %s", l.label)
+}
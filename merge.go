@@ -0,0 +1,233 @@
+package nflex
+
+import (
+	"strconv"
+)
+
+// MergeStrategy controls how a MultiSource combines the maps and
+// slices that more than one of its sources have at a path.  See
+// MultiSourceSetMergeStrategy.
+type MergeStrategy int
+
+const (
+	// MergeReplace uses the map or slice from the highest priority
+	// source that has the path and ignores the others
+	MergeReplace MergeStrategy = iota + 1
+	// MergeAppend concatenates slices, in the order the sources were
+	// given, and combines the keys of maps.  This is what
+	// MultiSourceSetCombine(true) does everywhere.
+	MergeAppend
+	// MergeAppendUnique concatenates slices and then drops elements
+	// that are equal to an earlier element
+	MergeAppendUnique
+	// MergeDeep combines maps key by key and slices element by
+	// element: element i of the result combines element i of each
+	// source.
+	MergeDeep
+
+	// mergeByKey is set with MultiSourceSetMergeByKey
+	mergeByKey MergeStrategy = -1
+)
+
+// mergeRule is a strategy and the paths it applies to
+type mergeRule struct {
+	path     []string
+	strategy MergeStrategy
+	key      string // for mergeByKey
+}
+
+func (r mergeRule) matches(path []string) bool {
+	if len(r.path) != len(path) {
+		return false
+	}
+	for i, p := range r.path {
+		if p != "*" && p != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MultiSourceSetMergeStrategy sets how a MultiSource combines the
+// maps or slices found at a path.  The path is from the top of the
+// MultiSource and a "*" in the path matches any key.  When more than
+// one strategy matches a path, the one set last is used.  Paths
+// without a strategy are combined as set by MultiSourceSetCombine.
+//
+//	m := MultiSourceSetMergeStrategy(MergeReplace, "servers").
+//		Combine(MultiSourceSetMergeStrategy(MergeAppendUnique, "tags")).
+//		Apply(NewMultiSource(overlay, base))
+func MultiSourceSetMergeStrategy(strategy MergeStrategy, path ...string) Mutation {
+	return func(source Source) Source {
+		if m, ok := source.(*MultiSource); ok {
+			c := m.Copy()
+			c.rules = append(c.rules[:len(c.rules):len(c.rules)], mergeRule{
+				path:     path,
+				strategy: strategy,
+			})
+			return c
+		}
+		return source
+	}
+}
+
+// MultiSourceSetMergeByKey makes a MultiSource merge the slices at a
+// path by matching up their elements, like the patchMergeKey of a
+// Kubernetes strategic merge patch.  Elements that are maps with the
+// same value for keyField are combined into one element as if they
+// were the same map in each source.  Other elements are appended.
+// The path is matched as in MultiSourceSetMergeStrategy.
+//
+//	# base                        # overlay
+//	listeners:                    listeners:
+//	  - name: http                  - name: http
+//	    port: 80                      port: 8080
+//	  - name: https
+//	    port: 443
+//
+// With MultiSourceSetMergeByKey("name", "listeners") and the
+// overlay first, listeners has two elements and listeners.0.port
+// is 8080.
+func MultiSourceSetMergeByKey(keyField string, path ...string) Mutation {
+	return func(source Source) Source {
+		if m, ok := source.(*MultiSource); ok {
+			c := m.Copy()
+			c.rules = append(c.rules[:len(c.rules):len(c.rules)], mergeRule{
+				path:     path,
+				strategy: mergeByKey,
+				key:      keyField,
+			})
+			return c
+		}
+		return source
+	}
+}
+
+// rule returns the merge rule for the current path
+func (m *MultiSource) rule() (mergeRule, bool) {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		if m.rules[i].matches(m.pathToHere) {
+			return m.rules[i], true
+		}
+	}
+	return mergeRule{}, false
+}
+
+// combined reports if maps and slices at the current path are
+// combined across sources
+func (m *MultiSource) combined() bool {
+	if rule, ok := m.rule(); ok {
+		return rule.strategy != MergeReplace
+	}
+	return m.combine
+}
+
+// child is the next step of recurse
+func (m *MultiSource) child(key string) *MultiSource {
+	var n []Source
+	rule, _ := m.rule()
+	switch {
+	case rule.strategy == MergeReplace:
+		if source, ok := m.winner(); ok {
+			if r := source.Recurse(key); r != nil {
+				n = append(n, r)
+			}
+		}
+	case m.remapped(rule):
+		elements := m.elements(rule)
+		if !isNumberRE.MatchString(key) {
+			break
+		}
+		i, err := strconv.Atoi(key)
+		if err == nil && i < len(elements) {
+			n = elements[i]
+		}
+	default:
+		var offset int
+		for _, source := range m.sources {
+			r := source
+			if source.Type() == Slice {
+				length, _ := source.Len()
+				if offset != 0 {
+					r = WithOffset(source, offset)
+				}
+				offset += length
+			}
+			if c := r.Recurse(key); c != nil {
+				n = append(n, c)
+			}
+		}
+	}
+	n = m.undeleted(n)
+	if len(n) == 0 {
+		return nil
+	}
+	return &MultiSource{
+		first:      m.first,
+		combine:    m.combine,
+		rules:      m.rules,
+		deletion:   m.deletion,
+		sources:    n,
+		pathToHere: combine(m.pathToHere, []string{key}),
+		debugID:    debugID(),
+	}
+}
+
+// remapped is true when slice elements do not simply follow each
+// other
+func (m *MultiSource) remapped(rule mergeRule) bool {
+	switch rule.strategy {
+	case MergeAppendUnique, MergeDeep, mergeByKey:
+		return m.Type() == Slice
+	default:
+		return false
+	}
+}
+
+// elements lists the sources that make up each element of a merged
+// slice
+func (m *MultiSource) elements(rule mergeRule) [][]Source {
+	var elements [][]Source
+	seen := make(map[string]int)
+	for _, source := range m.sources {
+		if source.Type() != Slice {
+			continue
+		}
+		length, _ := source.Len()
+		for i := 0; i < length; i++ {
+			e := source.Recurse(strconv.Itoa(i))
+			if e == nil {
+				continue
+			}
+			switch rule.strategy {
+			case MergeDeep:
+				if i < len(elements) {
+					elements[i] = append(elements[i], e)
+					continue
+				}
+			case MergeAppendUnique:
+				if enc, err := MarshalJSON(e); err == nil {
+					if _, dup := seen[string(enc)]; dup {
+						continue
+					}
+					seen[string(enc)] = len(elements)
+				}
+			case mergeByKey:
+				if e.Type() != Map || !e.Exists(rule.key) {
+					break
+				}
+				id, err := MarshalJSON(e.Recurse(rule.key))
+				if err != nil {
+					break
+				}
+				if j, ok := seen[string(id)]; ok {
+					elements[j] = append(elements[j], e)
+					continue
+				}
+				seen[string(id)] = len(elements)
+			}
+			elements = append(elements, []Source{e})
+		}
+	}
+	return elements
+}
@@ -0,0 +1,138 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func layers(t *testing.T, docs ...string) []Source {
+	sources := make([]Source, len(docs))
+	for i, doc := range docs {
+		var err error
+		sources[i], err = UnmarshalYAML([]byte(doc))
+		require.NoError(t, err)
+	}
+	return sources
+}
+
+const mergeBase = `
+servers: [a, b]
+plugins: [auth, log]
+tags: [web, api]
+limits:
+  cpu: 2
+  mem: 1G
+ports: [80, 443]
+nodes:
+  - name: a
+    size: 1
+  - name: b
+`
+
+const mergeOverlay = `
+servers: [c]
+plugins: [metrics]
+tags: [api, beta]
+limits:
+  cpu: 4
+ports: [8080]
+nodes:
+  - name: x
+`
+
+func TestMergeStrategies(t *testing.T) {
+	m := MultiSourceSetMergeStrategy(MergeReplace, "servers").
+		Combine(MultiSourceSetMergeStrategy(MergeAppend, "plugins")).
+		Combine(MultiSourceSetMergeStrategy(MergeAppendUnique, "tags")).
+		Combine(MultiSourceSetMergeStrategy(MergeDeep, "limits")).
+		Combine(MultiSourceSetMergeStrategy(MergeDeep, "nodes")).
+		Apply(NewMultiSource(layers(t, mergeOverlay, mergeBase)...))
+
+	got, err := MarshalJSON(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{"servers":["c"],"plugins":["metrics","auth","log"],"tags":["api","beta","web"],`+
+		`"limits":{"cpu":4,"mem":"1G"},"ports":[8080,80,443],"nodes":[{"name":"x","size":1},{"name":"b"}]}`, string(got))
+
+	assert.Equal(t, 3, getLen(t, m, "tags"))
+	tags := m.Recurse("tags")
+	require.NotNil(t, tags)
+	assert.Equal(t, 3, getLen(t, tags))
+	assert.Equal(t, "web", getString(t, tags, "2"))
+	assert.False(t, tags.Exists("3"))
+	assert.False(t, m.Exists("servers", "1"), "replaced")
+	assert.Equal(t, 1, getLen(t, m.Recurse("servers")))
+}
+
+func TestMergeStrategyPatterns(t *testing.T) {
+	sources := layers(t, mergeOverlay, mergeBase)
+
+	m := MultiSourceSetMergeStrategy(MergeReplace, "*").
+		Apply(NewMultiSource(sources...))
+	got, err := MarshalJSON(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{"servers":["c"],"plugins":["metrics"],"tags":["api","beta"],`+
+		`"limits":{"cpu":4},"ports":[8080],"nodes":[{"name":"x"}]}`, string(got))
+
+	m = MultiSourceSetCombine(false).
+		Combine(MultiSourceSetMergeStrategy(MergeAppend, "ports")).
+		Combine(MultiSourceSetMergeStrategy(MergeDeep, "nodes")).
+		Combine(MultiSourceSetMergeStrategy(MergeDeep, "nodes", "*")).
+		Apply(NewMultiSource(sources...))
+	got, err = MarshalJSON(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{"servers":["c"],"plugins":["metrics"],"tags":["api","beta"],`+
+		`"limits":{"cpu":4},"ports":[8080,80,443],"nodes":[{"name":"x","size":1},{"name":"b"}]}`, string(got))
+
+	last := MultiSourceSetFirst(false).Apply(m)
+	assert.Equal(t, "a", getString(t, last, "nodes", "0", "name"), "last source wins")
+}
+
+func TestMergeByKey(t *testing.T) {
+	sources := layers(t, `
+listeners:
+  - name: http
+    port: 8080
+  - name: admin
+    port: 9000
+  - plain
+`, `
+listeners:
+  - name: http
+    port: 80
+    tls: false
+  - name: https
+    port: 443
+`)
+	m := MultiSourceSetMergeByKey("name", "listeners").
+		Apply(NewMultiSource(sources...))
+
+	got, err := MarshalJSON(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{"listeners":[{"name":"http","port":8080,"tls":false},{"name":"admin","port":9000},"plain",{"name":"https","port":443}]}`, string(got))
+
+	assert.Equal(t, 4, getLen(t, m, "listeners"))
+	assert.Equal(t, []string{"name", "port", "tls"}, mustKeys(t, m, "listeners", "0"))
+	http := m.Recurse("listeners", "0")
+	require.NotNil(t, http)
+	port, err := http.GetInt("port")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8080), port)
+	assert.False(t, m.Exists("listeners", "4"))
+
+	p, ok := m.(*MultiSource).Explain("listeners", "0", "port")
+	require.True(t, ok)
+	require.Len(t, p.Shadowed, 1)
+	port, err = p.Winner.Source.GetInt()
+	require.NoError(t, err)
+	assert.Equal(t, int64(8080), port, "overlay wins")
+	port, err = p.Shadowed[0].Source.GetInt()
+	require.NoError(t, err)
+	assert.Equal(t, int64(80), port, "base is shadowed")
+
+	last := MultiSourceSetFirst(false).Apply(m)
+	port, err = last.GetInt("listeners", "0", "port")
+	require.NoError(t, err)
+	assert.Equal(t, int64(80), port)
+}
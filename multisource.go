@@ -1,6 +1,8 @@
 package nflex
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 )
 
@@ -10,6 +12,8 @@ type MultiSource struct {
 	sources    []Source
 	first      bool
 	combine    bool
+	rules      []mergeRule
+	deletion   DeletionMarker
 	debugID    int
 	pathToHere []string
 }
@@ -20,6 +24,8 @@ func (m *MultiSource) Copy() *MultiSource {
 	c := &MultiSource{
 		first:      m.first,
 		combine:    m.combine,
+		rules:      m.rules,
+		deletion:   m.deletion,
 		sources:    n,
 		debugID:    debugID(),
 		pathToHere: m.pathToHere,
@@ -60,6 +66,8 @@ func (m *MultiSource) Mutate(mutation Mutation) Source {
 	n := &MultiSource{
 		first:      m.first,
 		combine:    m.combine,
+		rules:      m.rules,
+		deletion:   m.deletion,
 		sources:    make([]Source, len(m.sources)),
 		debugID:    debugID(),
 		pathToHere: m.pathToHere,
@@ -107,6 +115,8 @@ func MultiSourceSetFirst(first bool) Mutation {
 //			key2: value2
 //
 // With combine=false, keys(map) = [key1] but lookup(map.key2) = value2
+//
+// MultiSourceSetMergeStrategy overrides this for particular paths.
 func MultiSourceSetCombine(combine bool) Mutation {
 	return func(source Source) Source {
 		if m, ok := source.(*MultiSource); ok {
@@ -161,38 +171,14 @@ func (m *MultiSource) recurse(keys ...string) *MultiSource {
 		return m
 	}
 	debug("nflex/multi: Recurse(", keys, ")", id(m), "-> ...")
-	n := make([]Source, 0, len(m.sources))
-	offsets := make([]int, len(keys))
-	for _, source := range m.sources {
-		r := source
-		for i, k := range keys {
-			r = r.Recurse(k)
-			if r == nil {
-				break
-			}
-			if r.Type() == Slice {
-				length, _ := r.Len()
-				if offsets[i] != 0 {
-					r = WithOffset(r, offsets[i])
-				}
-				offsets[i] += length
-			}
-		}
-		if r != nil {
-			n = append(n, r)
+	nm := m
+	for _, key := range keys {
+		nm = nm.child(key)
+		if nm == nil {
+			debug("nflex/multi: Recurse(", keys, ")", id(m), "-> nil")
+			return nil
 		}
 	}
-	if len(n) == 0 {
-		debug("nflex/multi: Recurse(", keys, ")", id(m), "-> nil")
-		return nil
-	}
-	nm := &MultiSource{
-		first:      m.first,
-		combine:    m.combine,
-		sources:    n,
-		pathToHere: debugCombine(m.pathToHere, keys),
-		debugID:    debugID(),
-	}
 	debug("nflex/multi: Recurse(", keys, ")", id(m), "-> ", id(nm), nm.debugKeys)
 	return nm
 }
@@ -203,6 +189,11 @@ func (m *MultiSource) find(keys []string) (Source, bool) {
 	if m == nil {
 		return nil, false
 	}
+	return m.winner()
+}
+
+// winner is the highest priority source that exists
+func (m *MultiSource) winner() (Source, bool) {
 	switch len(m.sources) {
 	case 0:
 		return nil, false
@@ -245,6 +236,13 @@ func (m *MultiSource) GetInt(keys ...string) (int64, error) {
 	return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
 }
 
+func (m *MultiSource) GetUInt(keys ...string) (uint64, error) {
+	if source, ok := m.find(keys); ok {
+		return GetUInt(source)
+	}
+	return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+}
+
 func (m *MultiSource) GetFloat(keys ...string) (float64, error) {
 	if source, ok := m.find(keys); ok {
 		return source.GetFloat()
@@ -259,6 +257,48 @@ func (m *MultiSource) GetString(keys ...string) (string, error) {
 	return "", errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
 }
 
+// Position returns the position of the value from the source that
+// provides it.  For maps and slices, that is the first source that
+// has them.
+func (m *MultiSource) Position(keys ...string) (string, int, int, bool) {
+	if source, ok := m.find(keys); ok {
+		return PositionOf(source)
+	}
+	return "", 0, 0, false
+}
+
+// keyPosition returns the position of the key from the highest
+// priority source that has it
+func (m *MultiSource) keyPosition(keys ...string) (string, int, int, bool) {
+	if len(keys) == 0 {
+		return "", 0, 0, false
+	}
+	parent := m.recurse(keys[:len(keys)-1]...)
+	if parent == nil {
+		return "", 0, 0, false
+	}
+	key := keys[len(keys)-1]
+	for i := range parent.sources {
+		source := parent.sources[i]
+		if !parent.first {
+			source = parent.sources[len(parent.sources)-1-i]
+		}
+		if source.Exists(key) {
+			return keyPositionOf(source, key)
+		}
+	}
+	return "", 0, 0, false
+}
+
+func (m *MultiSource) path() []string { return m.pathToHere }
+
+func (m *MultiSource) getTime(keys ...string) (time.Time, bool) {
+	if source, ok := m.find(keys); ok {
+		return nativeTime(source)
+	}
+	return time.Time{}, false
+}
+
 func (m *MultiSource) Type(keys ...string) NodeType {
 	if source, ok := m.find(keys); ok {
 		return source.Type()
@@ -266,24 +306,55 @@ func (m *MultiSource) Type(keys ...string) NodeType {
 	return Undefined
 }
 
+func (m *MultiSource) Tag(keys ...string) string {
+	if source, ok := m.find(keys); ok {
+		return TagOf(source)
+	}
+	return ""
+}
+
 func (m *MultiSource) Keys(keys ...string) ([]string, error) {
+	if len(keys) != 0 {
+		r := m.recurse(keys...)
+		if r == nil {
+			return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+		}
+		return r.Keys()
+	}
+	if m.deletion != nil {
+		found, err := m.keys()
+		if err != nil {
+			return nil, err
+		}
+		kept := found[:0]
+		for _, key := range found {
+			if m.child(key) != nil {
+				kept = append(kept, key)
+			}
+		}
+		return kept, nil
+	}
+	return m.keys()
+}
+
+func (m *MultiSource) keys() ([]string, error) {
 	if len(m.sources) == 1 {
-		return m.sources[0].Keys(keys...)
+		return m.sources[0].Keys()
 	}
-	if !m.combine {
-		if source, ok := m.find(keys); ok {
+	if !m.combined() {
+		if source, ok := m.winner(); ok {
 			return source.Keys()
 		}
-		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", m.pathToHere)
 	}
 	results := make([][]string, len(m.sources))
 	var total int
 	var able int
 	for i, source := range m.sources {
-		if !source.Exists(keys...) {
+		if !source.Exists() {
 			continue
 		}
-		found, err := source.Keys(keys...)
+		found, err := source.Keys()
 		if err != nil {
 			return nil, err
 		}
@@ -292,7 +363,7 @@ func (m *MultiSource) Keys(keys ...string) ([]string, error) {
 		able++
 	}
 	if able == 0 {
-		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", m.pathToHere)
 	}
 	combined := make([]string, 0, total)
 	seen := make(map[string]struct{})
@@ -309,22 +380,32 @@ func (m *MultiSource) Keys(keys ...string) ([]string, error) {
 }
 
 func (m *MultiSource) Len(keys ...string) (int, error) {
+	if len(keys) != 0 {
+		r := m.recurse(keys...)
+		if r == nil {
+			return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+		}
+		return r.Len()
+	}
 	if len(m.sources) == 1 {
-		return m.sources[0].Len(keys...)
+		return m.sources[0].Len()
+	}
+	if rule, _ := m.rule(); m.remapped(rule) {
+		return len(m.elements(rule)), nil
 	}
-	if !m.combine {
-		if source, ok := m.find(keys); ok {
+	if !m.combined() {
+		if source, ok := m.winner(); ok {
 			return source.Len()
 		}
-		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", m.pathToHere)
 	}
 	var able int
 	var total int
 	for _, source := range m.sources {
-		if !source.Exists(keys...) {
+		if !source.Exists() {
 			continue
 		}
-		l, err := source.Len(keys...)
+		l, err := source.Len()
 		if err != nil {
 			return 0, err
 		}
@@ -332,7 +413,7 @@ func (m *MultiSource) Len(keys ...string) (int, error) {
 		able++
 	}
 	if able == 0 {
-		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", m.pathToHere)
 	}
 	return total, nil
 }
@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -13,6 +14,11 @@ import (
 
 var ErrDoesNotExist = fmt.Errorf("requested item does not exist")
 var ErrWrongType = fmt.Errorf("requested item is not the requested type")
+var ErrNotMutable = fmt.Errorf("source cannot be modified")
+var ErrRequired = fmt.Errorf("required item is missing")
+var ErrUnknownKey = fmt.Errorf("key is not known")
+var ErrSchemaMismatch = fmt.Errorf("value does not match schema")
+var ErrTestFailed = fmt.Errorf("patch test failed")
 
 type NodeType int
 
@@ -43,10 +49,59 @@ type Source interface {
 	Type(keys ...string) NodeType
 }
 
+// UIntGetter is implemented by sources that can read unsigned
+// integers that are too large for an int64.  Use GetUInt to read
+// unsigned integers from any Source.
+type UIntGetter interface {
+	GetUInt(keys ...string) (uint64, error)
+}
+
+// GetUInt reads an unsigned integer.  Sources that are not a
+// UIntGetter are read with UIntFromInt.
+func GetUInt(source Source, keys ...string) (uint64, error) {
+	if u, ok := source.(UIntGetter); ok {
+		return u.GetUInt(keys...)
+	}
+	return UIntFromInt(source, keys...)
+}
+
+var _ UIntGetter = parsedYAML{}
+var _ UIntGetter = parsedJSON{}
+var _ UIntGetter = treeSource{}
+var _ UIntGetter = valueSource{}
+var _ UIntGetter = &MultiSource{}
+var _ UIntGetter = prefixSource{}
+var _ UIntGetter = offset{}
+var _ UIntGetter = labeledSource{}
+
+// UIntFromInt reads an unsigned integer with GetInt and GetString for
+// sources that do not have a better way to read unsigned integers.
+// Sources outside this package can use it to implement UIntGetter:
+//
+//	func (s mySource) GetUInt(keys ...string) (uint64, error) {
+//		return nflex.UIntFromInt(s, keys...)
+//	}
+func UIntFromInt(source Source, keys ...string) (uint64, error) {
+	i, err := source.GetInt(keys...)
+	if err == nil {
+		if i < 0 {
+			return 0, errors.Wrapf(ErrWrongType, "key %v is negative (%d)", keys, i)
+		}
+		return uint64(i), nil
+	}
+	if s, serr := source.GetString(keys...); serr == nil {
+		if u, perr := strconv.ParseUint(s, 10, 64); perr == nil {
+			return u, nil
+		}
+	}
+	return 0, err
+}
+
 var unmarshallers = map[string]func([]byte) (Source, error){
 	"yaml": UnmarshalYAML,
 	"yml":  UnmarshalYAML,
 	"json": UnmarshalJSON,
+	"toml": UnmarshalTOML,
 }
 
 type unmarshalOpts struct {
@@ -81,7 +136,11 @@ func UnmarshalFile(file string, args ...UnmarshalFileArg) (Source, error) {
 		return nil, errors.Wrapf(err, "read %s", file)
 	}
 
-	return uf(byts)
+	source, err := uf(byts)
+	if err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+	return WithLabel(source, file), nil
 }
 
 func combine(x []string, y []string) []string {
@@ -0,0 +1,105 @@
+package nflex
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Numbers that are read from text are typed and parsed here so that
+// Type agrees with GetInt and GetFloat for every source.  Untyped
+// text (plain YAML scalars, environment variables, flags) follows
+// the YAML 1.2 core schema.  JSON numbers follow the JSON grammar
+// which is a subset of the core schema.
+
+var coreIntRE = regexp.MustCompile(`^(?:[-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+var coreFloatRE = regexp.MustCompile(`^(?:[-+]?(?:\.[0-9]+|[0-9]+(?:\.[0-9]*)?)(?:[eE][-+]?[0-9]+)?|[-+]?\.(?:inf|Inf|INF)|\.(?:nan|NaN|NAN))$`)
+var jsonIntRE = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)$`)
+var jsonFloatRE = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?$`)
+
+// numberType returns Int or Float if s is a number in the YAML 1.2
+// core schema and String otherwise
+func numberType(s string) NodeType {
+	switch {
+	case coreIntRE.MatchString(s):
+		return Int
+	case coreFloatRE.MatchString(s):
+		return Float
+	default:
+		return String
+	}
+}
+
+// jsonNumberType types the text of a JSON number.  The JSON parser
+// also accepts some text that is not a JSON number, like "+3" or
+// "inf".  That is typed like an untyped YAML scalar.
+func jsonNumberType(s string) NodeType {
+	switch {
+	case jsonIntRE.MatchString(s):
+		return Int
+	case jsonFloatRE.MatchString(s):
+		return Float
+	default:
+		return numberType(s)
+	}
+}
+
+// intBase splits a core schema integer into its digits and base
+func intBase(s string) (string, int) {
+	switch {
+	case strings.HasPrefix(s, "0o"):
+		return s[2:], 8
+	case strings.HasPrefix(s, "0x"):
+		return s[2:], 16
+	default:
+		return s, 10
+	}
+}
+
+// parseInt parses integers that numberType says are Int
+func parseInt(s string) (int64, error) {
+	if !coreIntRE.MatchString(s) {
+		return 0, errors.Errorf("'%s' is not an integer", s)
+	}
+	digits, base := intBase(s)
+	return strconv.ParseInt(digits, base, 64)
+}
+
+// parseUInt parses integers that numberType says are Int and that
+// are not negative
+func parseUInt(s string) (uint64, error) {
+	if !coreIntRE.MatchString(s) {
+		return 0, errors.Errorf("'%s' is not an integer", s)
+	}
+	digits, base := intBase(strings.TrimPrefix(s, "+"))
+	return strconv.ParseUint(digits, base, 64)
+}
+
+// parseFloat parses numbers that numberType says are Int or Float
+func parseFloat(s string) (float64, error) {
+	switch {
+	case coreIntRE.MatchString(s):
+		digits, base := intBase(s)
+		if base == 10 {
+			return strconv.ParseFloat(digits, 64)
+		}
+		u, err := strconv.ParseUint(digits, base, 64)
+		return float64(u), err
+	case coreFloatRE.MatchString(s):
+		switch strings.ToLower(strings.TrimLeft(s, "+-")) {
+		case ".inf":
+			if s[0] == '-' {
+				return math.Inf(-1), nil
+			}
+			return math.Inf(1), nil
+		case ".nan":
+			return math.NaN(), nil
+		}
+		return strconv.ParseFloat(s, 64)
+	default:
+		return 0, errors.Errorf("'%s' is not a number", s)
+	}
+}
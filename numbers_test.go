@@ -0,0 +1,103 @@
+package nflex
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type numberCase struct {
+	text  string
+	yaml  NodeType
+	json  NodeType // Undefined if the JSON parser rejects text
+	i     interface{}
+	f     interface{}
+	u     interface{}
+	isNaN bool
+}
+
+// nil values for i, f, and u mean that the getter fails
+var numberCases = []numberCase{
+	{text: "5", yaml: Int, json: Int, i: int64(5), f: 5.0, u: uint64(5)},
+	{text: "0", yaml: Int, json: Int, i: int64(0), f: 0.0, u: uint64(0)},
+	{text: "-5", yaml: Int, json: Int, i: int64(-5), f: -5.0},
+	{text: "+3", yaml: Int, json: Int, i: int64(3), f: 3.0, u: uint64(3)},
+	{text: "007", yaml: Int, json: Int, i: int64(7), f: 7.0, u: uint64(7)},
+	{text: "0x1F", yaml: Int, i: int64(31), f: 31.0, u: uint64(31)},
+	{text: "0o17", yaml: Int, i: int64(15), f: 15.0, u: uint64(15)},
+	{text: "9223372036854775808", yaml: Int, json: Int, f: 9223372036854775808.0, u: uint64(9223372036854775808)},
+	{text: "1e3", yaml: Float, json: Float, f: 1000.0},
+	{text: "-2.5", yaml: Float, json: Float, f: -2.5},
+	{text: "2.0", yaml: Float, json: Float, f: 2.0},
+	{text: "-1.5E-2", yaml: Float, json: Float, f: -0.015},
+	{text: ".5", yaml: Float, json: Float, f: 0.5},
+	{text: "1.", yaml: Float, json: Float, f: 1.0},
+	{text: "+1e3", yaml: Float, json: Float, f: 1000.0},
+	{text: ".inf", yaml: Float, f: math.Inf(1)},
+	{text: "-.Inf", yaml: Float, f: math.Inf(-1)},
+	{text: ".NaN", yaml: Float, isNaN: true},
+	{text: "1_000", yaml: String},
+	{text: "0x", yaml: String},
+	{text: "0b101", yaml: String},
+	{text: "1.2.3", yaml: String, json: String},
+	{text: "inf", yaml: String, json: String},
+	{text: "f", yaml: String},
+	{text: "T", yaml: String},
+}
+
+func TestNumberConformance(t *testing.T) {
+	for _, tc := range numberCases {
+		tc := tc
+		t.Run(tc.text, func(t *testing.T) {
+			y, err := UnmarshalYAML([]byte("v: " + tc.text))
+			require.NoError(t, err)
+			checkNumber(t, "yaml", y, tc.yaml, tc)
+
+			env := NewEnvSource("X_", "_", WithEnviron([]string{"X_V=" + tc.text}))
+			checkNumber(t, "env", env, tc.yaml, tc)
+
+			j, err := UnmarshalJSON([]byte(`{"v":` + tc.text + `}`))
+			if tc.json == Undefined {
+				assert.Error(t, err, "not a JSON number")
+				return
+			}
+			require.NoError(t, err)
+			checkNumber(t, "json", j, tc.json, tc)
+		})
+	}
+}
+
+func checkNumber(t *testing.T, format string, s Source, want NodeType, tc numberCase) {
+	assert.Equal(t, want, s.Type("v"), format+" type")
+	if want == String {
+		str, err := s.GetString("v")
+		if assert.NoError(t, err, format+" string") {
+			assert.Equal(t, tc.text, str, format+" string")
+		}
+		return
+	}
+
+	i, err := s.GetInt("v")
+	if tc.i == nil {
+		assert.ErrorIs(t, err, ErrWrongType, format+" int")
+	} else if assert.NoError(t, err, format+" int") {
+		assert.Equal(t, tc.i, i, format+" int")
+	}
+
+	u, err := GetUInt(s, "v")
+	if tc.u == nil {
+		assert.ErrorIs(t, err, ErrWrongType, format+" uint")
+	} else if assert.NoError(t, err, format+" uint") {
+		assert.Equal(t, tc.u, u, format+" uint")
+	}
+
+	f, err := s.GetFloat("v")
+	require.NoError(t, err, format+" float")
+	if tc.isNaN {
+		assert.True(t, math.IsNaN(f), format+" float")
+	} else {
+		assert.Equal(t, tc.f, f, format+" float")
+	}
+}
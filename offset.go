@@ -2,6 +2,7 @@ package nflex
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -41,6 +42,32 @@ func (o offset) transform(keys []string) ([]string, error) {
 	return n, nil
 }
 
+func (o offset) Label() string { return SourceLabel(o.source) }
+
+func (o offset) Position(keys ...string) (string, int, int, bool) {
+	tk, err := o.transform(keys)
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return PositionOf(o.source, tk...)
+}
+
+func (o offset) keyPosition(keys ...string) (string, int, int, bool) {
+	tk, err := o.transform(keys)
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return keyPositionOf(o.source, tk...)
+}
+
+func (o offset) Tag(keys ...string) string {
+	tk, err := o.transform(keys)
+	if err != nil {
+		return ""
+	}
+	return TagOf(o.source, tk...)
+}
+
 func (o offset) Exists(keys ...string) bool {
 	tk, err := o.transform(keys)
 	if err != nil {
@@ -65,6 +92,22 @@ func (o offset) GetInt(keys ...string) (int64, error) {
 	return o.source.GetInt(tk...)
 }
 
+func (o offset) GetUInt(keys ...string) (uint64, error) {
+	tk, err := o.transform(keys)
+	if err != nil {
+		return 0, err
+	}
+	return GetUInt(o.source, tk...)
+}
+
+func (o offset) getTime(keys ...string) (time.Time, bool) {
+	tk, err := o.transform(keys)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return nativeTime(o.source, tk...)
+}
+
 func (o offset) GetFloat(keys ...string) (float64, error) {
 	tk, err := o.transform(keys)
 	if err != nil {
@@ -124,3 +167,25 @@ func (o offset) Type(keys ...string) NodeType {
 	}
 	return o.source.Type(tk...)
 }
+
+func (o offset) Set(value interface{}, keys ...string) error {
+	tk, err := o.transform(keys)
+	if err != nil {
+		return err
+	}
+	if m, ok := o.source.(MutableSource); ok {
+		return m.Set(value, tk...)
+	}
+	return errors.Wrapf(ErrNotMutable, "key %v", keys)
+}
+
+func (o offset) Delete(keys ...string) error {
+	tk, err := o.transform(keys)
+	if err != nil {
+		return err
+	}
+	if m, ok := o.source.(MutableSource); ok {
+		return m.Delete(tk...)
+	}
+	return errors.Wrapf(ErrNotMutable, "key %v", keys)
+}
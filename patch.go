@@ -0,0 +1,458 @@
+package nflex
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to base and returns
+// the result as a new Source.  base is not changed.  Keys in the
+// patch with null values are removed, maps are merged, and
+// everything else replaces what is in base.
+//
+//	merged, err := nflex.MergePatch(config, patch)
+func MergePatch(base Source, patch Source) (Source, error) {
+	doc, err := sourceValue(base, nil)
+	if err != nil && !errors.Is(err, ErrDoesNotExist) {
+		return nil, err
+	}
+	doc, err = mergePatch(doc, patch, nil)
+	if err != nil {
+		return nil, err
+	}
+	return patchedSource("nflex/MergePatch", doc), nil
+}
+
+func mergePatch(doc interface{}, patch Source, keys []string) (interface{}, error) {
+	if patch.Type(keys...) != Map {
+		return sourceValue(patch, keys)
+	}
+	m, ok := doc.(*orderedMap)
+	if !ok {
+		m = newOrderedMap()
+	}
+	patchKeys, err := patch.Keys(keys...)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range patchKeys {
+		p := combine(keys, []string{key})
+		if patch.Type(p...) == Nil {
+			m.remove(key)
+			continue
+		}
+		v, err := mergePatch(m.values[key], patch, p)
+		if err != nil {
+			return nil, err
+		}
+		m.set(key, v)
+	}
+	return m, nil
+}
+
+// JSONPatch applies a JSON Patch (RFC 6902) to base and returns the
+// result as a new Source.  base is not changed.  The patch is an
+// array of operations: add, remove, replace, move, copy, and test.
+// Paths are JSON Pointers, as read by ParsePointer.  If any
+// operation fails, no result is returned.  A failed test operation
+// is an error that matches ErrTestFailed.  Paths that do not exist
+// are errors that match ErrDoesNotExist.
+//
+//	patch, err := nflex.UnmarshalJSON([]byte(`[
+//		{"op": "replace", "path": "/servers/0/port", "value": 8443},
+//		{"op": "remove", "path": "/debug"}
+//	]`))
+//	patched, err := nflex.JSONPatch(config, patch)
+func JSONPatch(base Source, patch Source) (Source, error) {
+	if patch.Type() != Slice {
+		return nil, errors.Wrapf(ErrWrongType, "JSON patch must be an array of operations, not a %s%s",
+			patch.Type(), positionSuffix(PositionOf(patch)))
+	}
+	doc, err := sourceValue(base, nil)
+	if err != nil {
+		return nil, err
+	}
+	length, err := patch.Len()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < length; i++ {
+		doc, err = applyOperation(doc, patch, strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return patchedSource("nflex/JSONPatch", doc), nil
+}
+
+func patchedSource(name string, doc interface{}) Source {
+	p := valueSource{
+		value:   doc,
+		debugID: debugID(),
+	}
+	debug(name, p.debugID, p.debugKeys)
+	return p
+}
+
+func applyOperation(doc interface{}, patch Source, i string) (interface{}, error) {
+	op, err := patch.GetString(i, "op")
+	if err == nil {
+		doc, err = applyOp(doc, patch, i, op)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "patch operation %s (%s)%s", i, op, positionSuffix(PositionOf(patch, i)))
+	}
+	return doc, nil
+}
+
+func applyOp(doc interface{}, patch Source, i string, op string) (interface{}, error) {
+	pointer := func(name string) ([]string, error) {
+		s, err := patch.GetString(i, name)
+		if err != nil {
+			return nil, err
+		}
+		return ParsePointer(s)
+	}
+	path, err := pointer("path")
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "add", "replace", "test":
+		if !patch.Exists(i, "value") {
+			return nil, errors.Wrapf(ErrDoesNotExist, "no value")
+		}
+		v, err := sourceValue(patch, []string{i, "value"})
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "add":
+			return patchAdd(doc, path, v)
+		case "replace":
+			return patchReplace(doc, path, v)
+		}
+		current, ok := patchGet(doc, path)
+		if !ok {
+			return nil, errors.Wrapf(ErrTestFailed, "%v does not exist", path)
+		}
+		if !equalValues(current, v) {
+			return nil, errors.Wrapf(ErrTestFailed, "%v does not have the expected value", path)
+		}
+		return doc, nil
+	case "remove":
+		doc, _, err = patchRemove(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := pointer("from")
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if op == "move" {
+			if len(from) < len(path) && keysPrefix(from, path) {
+				return nil, errors.Errorf("cannot move %v into itself", from)
+			}
+			doc, v, err = patchRemove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			var ok bool
+			v, ok = patchGet(doc, from)
+			if !ok {
+				return nil, errors.Wrapf(ErrDoesNotExist, "%v does not exist", from)
+			}
+			v = copyValue(v)
+		}
+		return patchAdd(doc, path, v)
+	default:
+		return nil, errors.Errorf("unknown op")
+	}
+}
+
+func keysPrefix(prefix []string, keys []string) bool {
+	for i, key := range prefix {
+		if keys[i] != key {
+			return false
+		}
+	}
+	return true
+}
+
+// patchIndex reads an array index from a JSON Pointer.  "-" is
+// the end of the array.
+func patchIndex(key string, length int, end bool) (int, error) {
+	if key == "-" && end {
+		return length, nil
+	}
+	if !isArrayIndex(key) {
+		return 0, errors.Wrapf(ErrWrongType, "'%s' is not an array index", key)
+	}
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, errors.Wrapf(ErrWrongType, "'%s' is not an array index: %s", key, err)
+	}
+	if i > length || i == length && !end {
+		return 0, errors.Wrapf(ErrDoesNotExist, "index %d is past the end of the array", i)
+	}
+	return i, nil
+}
+
+// isArrayIndex checks for the array indexes of RFC 6901: 0 or
+// digits without a leading zero
+func isArrayIndex(key string) bool {
+	if key == "" || key[0] == '0' && key != "0" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '0' || key[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func patchGet(doc interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch d := doc.(type) {
+		case *orderedMap:
+			var ok bool
+			doc, ok = d.values[key]
+			if !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := patchIndex(key, len(d), false)
+			if err != nil {
+				return nil, false
+			}
+			doc = d[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// patchParent calls change with the container that holds the last
+// key of path.  change returns the container to use in its place
+// because slices can change length.
+func patchParent(doc interface{}, path []string, change func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+	key := path[0]
+	switch d := doc.(type) {
+	case *orderedMap:
+		child, ok := d.values[key]
+		if !ok {
+			return nil, errors.Wrapf(ErrDoesNotExist, "key '%s' does not exist", key)
+		}
+		child, err := patchParent(child, path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		d.values[key] = child
+		return d, nil
+	case []interface{}:
+		i, err := patchIndex(key, len(d), false)
+		if err != nil {
+			return nil, err
+		}
+		d[i], err = patchParent(d[i], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	default:
+		return nil, errors.Wrapf(ErrDoesNotExist, "'%s' is not in a map or array", key)
+	}
+}
+
+func patchAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return patchParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case *orderedMap:
+			p.set(key, value)
+			return p, nil
+		case []interface{}:
+			i, err := patchIndex(key, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		default:
+			return nil, errors.Wrapf(ErrDoesNotExist, "'%s' is not in a map or array", key)
+		}
+	})
+}
+
+func patchReplace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return patchParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case *orderedMap:
+			if _, ok := p.values[key]; !ok {
+				return nil, errors.Wrapf(ErrDoesNotExist, "key '%s' does not exist", key)
+			}
+			p.values[key] = value
+			return p, nil
+		case []interface{}:
+			i, err := patchIndex(key, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			p[i] = value
+			return p, nil
+		default:
+			return nil, errors.Wrapf(ErrDoesNotExist, "'%s' is not in a map or array", key)
+		}
+	})
+}
+
+// patchRemove removes the value at path and returns it
+func patchRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.Errorf("cannot remove the whole document")
+	}
+	var removed interface{}
+	doc, err := patchParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case *orderedMap:
+			var ok bool
+			removed, ok = p.values[key]
+			if !ok {
+				return nil, errors.Wrapf(ErrDoesNotExist, "key '%s' does not exist", key)
+			}
+			p.remove(key)
+			return p, nil
+		case []interface{}:
+			i, err := patchIndex(key, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = p[i]
+			return append(p[:i], p[i+1:]...), nil
+		default:
+			return nil, errors.Wrapf(ErrDoesNotExist, "'%s' is not in a map or array", key)
+		}
+	})
+	return doc, removed, err
+}
+
+func (m *orderedMap) remove(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// sourceValue copies a Source, or part of one, into the values that
+// valueSource uses, keeping the order of keys
+func sourceValue(source Source, keys []string) (interface{}, error) {
+	switch source.Type(keys...) {
+	case Map:
+		mk, err := source.Keys(keys...)
+		if err != nil {
+			return nil, err
+		}
+		m := newOrderedMap()
+		for _, k := range mk {
+			e, err := sourceValue(source, combine(keys, []string{k}))
+			if err != nil {
+				return nil, err
+			}
+			m.set(k, e)
+		}
+		return m, nil
+	case Slice:
+		length, err := source.Len(keys...)
+		if err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, length)
+		for i := range a {
+			a[i], err = sourceValue(source, combine(keys, []string{strconv.Itoa(i)}))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	default:
+		return ToInterface(source, keys...)
+	}
+}
+
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case *orderedMap:
+		m := newOrderedMap()
+		for _, k := range t.keys {
+			m.set(k, copyValue(t.values[k]))
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = copyValue(e)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+// equalValues compares values the way JSON Patch test does: the
+// order of keys does not matter and numbers are equal if they have
+// the same value
+func equalValues(a, b interface{}) bool {
+	switch at := a.(type) {
+	case *orderedMap:
+		bt, ok := b.(*orderedMap)
+		if !ok || len(at.keys) != len(bt.keys) {
+			return false
+		}
+		for k, av := range at.values {
+			bv, ok := bt.values[k]
+			if !ok || !equalValues(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !equalValues(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	case int64, uint64, float64:
+		switch b.(type) {
+		case int64, uint64, float64:
+			return numbersAsFloats(a) == numbersAsFloats(b)
+		}
+		return false
+	default:
+		return a == b
+	}
+}
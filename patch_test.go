@@ -0,0 +1,144 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustJSON(t *testing.T, doc string) Source {
+	s, err := UnmarshalJSON([]byte(doc))
+	require.NoError(t, err, doc)
+	return s
+}
+
+// Examples from RFC 7386 appendix A
+func TestMergePatch(t *testing.T) {
+	cases := []struct {
+		base  string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		base := mustJSON(t, tc.base)
+		got, err := MergePatch(base, mustJSON(t, tc.patch))
+		require.NoError(t, err, tc.patch)
+		enc, err := MarshalJSON(got)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, string(enc), "%s + %s", tc.base, tc.patch)
+
+		enc, err = MarshalJSON(base)
+		require.NoError(t, err)
+		assert.Equal(t, tc.base, string(enc), "base is unchanged")
+	}
+
+	base, err := UnmarshalYAML([]byte("name: app\nservers:\n  - host: a\nport: 80\n"))
+	require.NoError(t, err)
+	got, err := MergePatch(base, mustJSON(t, `{"port":null,"servers":[{"host":"b"}],"debug":true}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "servers", "debug"}, mustKeys(t, got))
+	assert.Equal(t, "b", getString(t, got, "servers", "0", "host"))
+	assert.Equal(t, []string{"name", "servers", "port"}, mustKeys(t, base), "base is unchanged")
+}
+
+func TestJSONPatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		base  string
+		patch string
+		want  string
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{"add element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"add end", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{"copy", `{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`,
+			`{"a":{"b":[1]},"c":{"b":[1,2]}}`},
+		{"test", `{"baz":"qux","foo":["a",2,"c"],"n":{"x":1,"y":2}}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0},{"op":"test","path":"/n","value":{"y":2,"x":1}}]`,
+			`{"baz":"qux","foo":["a",2,"c"],"n":{"x":1,"y":2}}`},
+		{"escapes", `{"/":9,"~1":10}`, `[{"op":"replace","path":"/~1","value":1},{"op":"remove","path":"/~01"}]`, `{"/":1}`},
+		{"nested", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"whole", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+	for _, tc := range cases {
+		base := mustJSON(t, tc.base)
+		got, err := JSONPatch(base, mustJSON(t, tc.patch))
+		require.NoError(t, err, tc.name)
+		enc, err := MarshalJSON(got)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, string(enc), tc.name)
+
+		enc, err = MarshalJSON(base)
+		require.NoError(t, err)
+		assert.Equal(t, tc.base, string(enc), "%s: base is unchanged", tc.name)
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	base := mustJSON(t, `{"foo":"bar","list":[1,2],"m":{"a":1}}`)
+	cases := []struct {
+		name  string
+		patch string
+		want  error
+	}{
+		{"test fails", `[{"op":"test","path":"/foo","value":"baz"}]`, ErrTestFailed},
+		{"test missing", `[{"op":"test","path":"/nope","value":1}]`, ErrTestFailed},
+		{"test number", `[{"op":"test","path":"/list/0","value":"1"}]`, ErrTestFailed},
+		{"remove missing", `[{"op":"remove","path":"/nope"}]`, ErrDoesNotExist},
+		{"replace missing", `[{"op":"replace","path":"/nope","value":1}]`, ErrDoesNotExist},
+		{"add no parent", `[{"op":"add","path":"/a/b","value":1}]`, ErrDoesNotExist},
+		{"add past end", `[{"op":"add","path":"/list/3","value":1}]`, ErrDoesNotExist},
+		{"leading zero", `[{"op":"replace","path":"/list/01","value":1}]`, ErrWrongType},
+		{"negative zero", `[{"op":"replace","path":"/list/-0","value":1}]`, ErrWrongType},
+		{"plus", `[{"op":"remove","path":"/list/+1"}]`, ErrWrongType},
+		{"empty index", `[{"op":"add","path":"/list/","value":1}]`, ErrWrongType},
+		{"no value", `[{"op":"add","path":"/a"}]`, ErrDoesNotExist},
+		{"no from", `[{"op":"copy","path":"/a"}]`, ErrDoesNotExist},
+		{"no op", `[{"path":"/a"}]`, ErrDoesNotExist},
+		{"not array", `{"op":"add","path":"/a","value":1}`, ErrWrongType},
+		{"bad op", `[{"op":"frob","path":"/a"}]`, nil},
+		{"bad pointer", `[{"op":"remove","path":"foo"}]`, nil},
+		{"move into self", `[{"op":"move","from":"/m","path":"/m/b"}]`, nil},
+		{"later fails", `[{"op":"add","path":"/x","value":1},{"op":"test","path":"/x","value":2}]`, ErrTestFailed},
+	}
+	for _, tc := range cases {
+		got, err := JSONPatch(base, mustJSON(t, tc.patch))
+		assert.Nil(t, got, tc.name)
+		if assert.Error(t, err, tc.name) && tc.want != nil {
+			assert.ErrorIs(t, err, tc.want, tc.name)
+		}
+	}
+
+	patch, err := UnmarshalYAML([]byte("- op: add\n  path: /a\n  value: 1\n- op: test\n  path: /a\n  value: 2\n"))
+	require.NoError(t, err)
+	_, err = JSONPatch(base, WithLabel(patch, "patch.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "patch operation 1 (test)")
+	assert.Contains(t, err.Error(), "patch.yaml:4:3")
+	assert.False(t, base.Exists("a"))
+}
@@ -0,0 +1,246 @@
+package nflex
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// pathTree is built from a flat set of paths, like environment
+// variables or command-line flags.  Nodes that have children are
+// containers: a Slice if the children are exactly 0..n-1 and a Map
+// otherwise.  A leaf value on a node that also has children is
+// ignored.
+type pathTree struct {
+	leaf     treeLeaf
+	children map[string]*pathTree
+	keys     []string
+}
+
+// treeLeaf is a scalar value in a pathTree.  Errors returned by
+// the getters are wrapped by treeSource.
+type treeLeaf interface {
+	Type() NodeType
+	Bool() (bool, error)
+	Int() (int64, error)
+	UInt() (uint64, error)
+	Float() (float64, error)
+	String() string
+}
+
+func (t *pathTree) add(path []string, leaf treeLeaf) {
+	for _, key := range path {
+		if t.children == nil {
+			t.children = make(map[string]*pathTree)
+		}
+		c, ok := t.children[key]
+		if !ok {
+			c = &pathTree{}
+			t.children[key] = c
+			t.keys = append(t.keys, key)
+		}
+		t = c
+	}
+	t.leaf = leaf
+}
+
+func (t *pathTree) lookup(keys []string) *pathTree {
+	for _, key := range keys {
+		if t == nil {
+			return nil
+		}
+		t = t.children[key]
+	}
+	return t
+}
+
+func (t *pathTree) isContainer() bool {
+	return len(t.children) > 0
+}
+
+func (t *pathTree) isSlice() bool {
+	if len(t.children) == 0 {
+		return false
+	}
+	for i := range t.keys {
+		if _, ok := t.children[strconv.Itoa(i)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *pathTree) nodeType() NodeType {
+	switch {
+	case t.isSlice():
+		return Slice
+	case t.isContainer():
+		return Map
+	case t.leaf == nil:
+		return Nil
+	default:
+		return t.leaf.Type()
+	}
+}
+
+// treeSource is a Source over a pathTree
+type treeSource struct {
+	tree       *pathTree
+	debugID    int
+	pathToHere []string
+	label      string
+}
+
+func newTreeSource(tree *pathTree, label string) treeSource {
+	return treeSource{
+		tree:    tree,
+		debugID: debugID(),
+		label:   label,
+	}
+}
+
+func (s treeSource) Exists(keys ...string) bool {
+	return s.tree.lookup(keys) != nil
+}
+
+func (s treeSource) Recurse(keys ...string) Source {
+	if len(keys) == 0 {
+		debug("nflex/tree Recurse()", id(s), "-> self")
+		return s
+	}
+	t := s.tree.lookup(keys)
+	if t == nil {
+		debug("nflex/tree Recurse(", keys, ")", id(s), "-> nil")
+		return nil
+	}
+	n := treeSource{
+		tree:       t,
+		pathToHere: combine(s.pathToHere, keys),
+		debugID:    debugID(),
+		label:      s.label,
+	}
+	debug("nflex/tree Recurse(", keys, ")", id(s), "->", id(n))
+	return n
+}
+
+func (s treeSource) lookupScalar(keys []string) (treeLeaf, error) {
+	t := s.tree.lookup(keys)
+	if t == nil {
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(s.pathToHere, keys))
+	}
+	if t.isContainer() || t.leaf == nil {
+		return nil, errors.Wrapf(ErrWrongType, "key %v is a %s (not a scalar)", combine(s.pathToHere, keys), t.nodeType())
+	}
+	return t.leaf, nil
+}
+
+func (s treeSource) GetBool(keys ...string) (bool, error) {
+	leaf, err := s.lookupScalar(keys)
+	if err != nil {
+		return false, err
+	}
+	b, err := leaf.Bool()
+	if err != nil {
+		return false, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(s.pathToHere, keys), err)
+	}
+	return b, nil
+}
+
+func (s treeSource) GetInt(keys ...string) (int64, error) {
+	leaf, err := s.lookupScalar(keys)
+	if err != nil {
+		return 0, err
+	}
+	i, err := leaf.Int()
+	if err != nil {
+		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(s.pathToHere, keys), err)
+	}
+	return i, nil
+}
+
+func (s treeSource) GetUInt(keys ...string) (uint64, error) {
+	leaf, err := s.lookupScalar(keys)
+	if err != nil {
+		return 0, err
+	}
+	i, err := leaf.UInt()
+	if err != nil {
+		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(s.pathToHere, keys), err)
+	}
+	return i, nil
+}
+
+func (s treeSource) GetFloat(keys ...string) (float64, error) {
+	leaf, err := s.lookupScalar(keys)
+	if err != nil {
+		return 0, err
+	}
+	f, err := leaf.Float()
+	if err != nil {
+		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(s.pathToHere, keys), err)
+	}
+	return f, nil
+}
+
+func (s treeSource) GetString(keys ...string) (string, error) {
+	leaf, err := s.lookupScalar(keys)
+	if err != nil {
+		return "", err
+	}
+	return leaf.String(), nil
+}
+
+func (s treeSource) Keys(keys ...string) ([]string, error) {
+	t := s.tree.lookup(keys)
+	if t == nil {
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(s.pathToHere, keys))
+	}
+	if t.nodeType() != Map {
+		return nil, errors.Wrapf(ErrWrongType, "key %v is a %s (not a map)", combine(s.pathToHere, keys), t.nodeType())
+	}
+	ret := make([]string, len(t.keys))
+	copy(ret, t.keys)
+	return ret, nil
+}
+
+func (s treeSource) Len(keys ...string) (int, error) {
+	t := s.tree.lookup(keys)
+	if t == nil {
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(s.pathToHere, keys))
+	}
+	if t.nodeType() != Slice {
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not an array)", combine(s.pathToHere, keys), t.nodeType())
+	}
+	return len(t.keys), nil
+}
+
+func (s treeSource) Type(keys ...string) NodeType {
+	t := s.tree.lookup(keys)
+	if t == nil {
+		return Undefined
+	}
+	return t.nodeType()
+}
+
+func (s treeSource) Label() string  { return s.label }
+func (s treeSource) path() []string { return s.pathToHere }
+
+func (s treeSource) withLabel(label string) Source {
+	s.label = label
+	return s
+}
+
+func (s treeSource) debugKeys() string {
+	return debugKeys(s)
+}
+
+// stringLeaf is an untyped scalar.  It is typed the same way
+// that YAML scalars are.
+type stringLeaf string
+
+func (l stringLeaf) Type() NodeType          { return scalarType(string(l)) }
+func (l stringLeaf) Bool() (bool, error)     { return strconv.ParseBool(string(l)) }
+func (l stringLeaf) Int() (int64, error)     { return parseInt(string(l)) }
+func (l stringLeaf) UInt() (uint64, error)   { return parseUInt(string(l)) }
+func (l stringLeaf) Float() (float64, error) { return parseFloat(string(l)) }
+func (l stringLeaf) String() string          { return string(l) }
@@ -0,0 +1,65 @@
+package nflex
+
+import (
+	"strconv"
+)
+
+// Positioner is implemented by sources that know where each value
+// is in the file it was read from.  Lines and columns start at 1.
+// File is the label of the source (see WithLabel) and may be empty.
+type Positioner interface {
+	Position(keys ...string) (file string, line, col int, ok bool)
+}
+
+// PositionOf returns the position of a value if the source knows it
+func PositionOf(source Source, keys ...string) (file string, line, col int, ok bool) {
+	if p, isPositioner := source.(Positioner); isPositioner {
+		return p.Position(keys...)
+	}
+	return "", 0, 0, false
+}
+
+// keyPositioner is implemented by sources that know where the keys
+// of maps are, as well as where their values are
+type keyPositioner interface {
+	keyPosition(keys ...string) (file string, line, col int, ok bool)
+}
+
+// keyPositionOf returns the position of the key of the map entry
+// named by keys.  If the source does not know it, it returns the
+// position of the value.
+func keyPositionOf(source Source, keys ...string) (file string, line, col int, ok bool) {
+	if p, isKeyPositioner := source.(keyPositioner); isKeyPositioner && len(keys) != 0 {
+		if file, line, col, ok := p.keyPosition(keys...); ok {
+			return file, line, col, ok
+		}
+	}
+	return PositionOf(source, keys...)
+}
+
+func formatPosition(file string, line, col int) string {
+	pos := strconv.Itoa(line) + ":" + strconv.Itoa(col)
+	if file == "" {
+		return pos
+	}
+	return file + ":" + pos
+}
+
+// positionSuffix is appended to error messages about a value
+func positionSuffix(file string, line, col int, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return " at " + formatPosition(file, line, col)
+}
+
+// nearSuffix is appended to error messages about a value that does
+// not exist: it gives the position of the closest enclosing value.
+func nearSuffix(p Positioner, keys []string) string {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if file, line, col, ok := p.Position(keys[:i]...); ok {
+			return " (in " + formatPosition(file, line, col) + ")"
+		}
+	}
+	return ""
+}
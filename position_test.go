@@ -0,0 +1,113 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pos struct {
+	file string
+	line int
+	col  int
+	ok   bool
+}
+
+func position(s Source, keys ...string) pos {
+	file, line, col, ok := PositionOf(s, keys...)
+	return pos{file: file, line: line, col: col, ok: ok}
+}
+
+func TestPositionYAML(t *testing.T) {
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+	assert.Equal(t, pos{"common.yaml", 4, 10, true}, position(y, "a", "b", "i"))
+	assert.Equal(t, pos{"common.yaml", 9, 14, true}, position(y, "a", "b", "a", "1"))
+	assert.Equal(t, pos{"common.yaml", 9, 14, true}, position(y.Recurse("a", "b"), "a", "1"))
+	assert.Equal(t, pos{"common.yaml", 9, 14, true}, position(y.Recurse("a", "b", "a", "1")))
+	assert.False(t, position(y, "a", "nope").ok)
+
+	_, err = y.GetInt("a", "b", "s")
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.Contains(t, err.Error(), "at common.yaml:6:10")
+	_, err = y.GetInt("a", "b", "m", "nope")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+	assert.Contains(t, err.Error(), "(in common.yaml:11:9)")
+}
+
+func TestPositionJSON(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	assert.Equal(t, pos{"common.json", 4, 9, true}, position(j, "a", "b", "i"))
+	assert.Equal(t, pos{"common.json", 11, 5, true}, position(j, "a", "b", "a", "1"))
+	assert.Equal(t, pos{"common.json", 11, 5, true}, position(j.Recurse("a", "b"), "a", "1"))
+	assert.Equal(t, pos{"common.json", 1, 1, true}, position(j))
+	assert.False(t, position(j, "a", "nope").ok)
+
+	_, err = j.GetString("a", "b", "i")
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.Contains(t, err.Error(), "at common.json:4:9")
+	_, err = j.GetInt("a", "b", "nope")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+	assert.Contains(t, err.Error(), "(in common.json:3:8)")
+
+	s, err := UnmarshalJSON([]byte("{\"é\\u00e9\": [1,\n  {\"k\": \"v\\\"]\", \"k\": 2}]}"))
+	require.NoError(t, err)
+	assert.Equal(t, pos{"", 1, 14, true}, position(s, "éé", "0"))
+	assert.Equal(t, pos{"", 2, 9, true}, position(s, "éé", "1", "k"))
+}
+
+func TestPositionWrappers(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+	m := NewMultiSource(j, y)
+	assert.Equal(t, pos{"common.json", 4, 9, true}, position(m, "a", "b", "i"))
+	assert.Equal(t, pos{"common.yaml", 14, 12, true}, position(m, "a", "b", "d", "y"))
+	assert.Equal(t, pos{"common.yaml", 9, 14, true}, position(m, "a", "b", "a", "3"))
+	assert.False(t, position(m, "nope").ok)
+
+	p := NewPrefixSource(y.Recurse("a"), "x", "a")
+	assert.Equal(t, pos{"common.yaml", 4, 10, true}, position(p, "x", "a", "b", "i"))
+	assert.False(t, position(p, "x").ok)
+
+	l := WithLabel(NewMultiSource(NewEnvSource("APP_", "_", WithEnviron([]string{})), y), "merged")
+	assert.Equal(t, pos{"common.yaml", 4, 10, true}, position(l, "a", "b", "i"))
+	assert.False(t, position(NewEnvSource("APP_", "_", WithEnviron([]string{"APP_X=1"})), "x").ok)
+}
+
+func TestPositionJSONEdited(t *testing.T) {
+	s, err := UnmarshalJSON([]byte(`{"a": [1, 2, 3]}`))
+	require.NoError(t, err)
+	assert.Equal(t, pos{"", 1, 11, true}, position(s, "a", "1"))
+	require.NoError(t, s.(MutableSource).Delete("a", "0"))
+	assert.False(t, position(s, "a", "1").ok, "stale after delete")
+	assert.False(t, position(s.Recurse("a"), "0").ok, "stale after delete")
+
+	s, err = UnmarshalJSON([]byte(`{"a": 1}`))
+	require.NoError(t, err)
+	require.NoError(t, s.(MutableSource).Set(2, "b"))
+	assert.False(t, position(s, "a").ok, "not scanned before set")
+}
+
+func TestKeyPosition(t *testing.T) {
+	keyPosition := func(s Source, keys ...string) pos {
+		file, line, col, ok := keyPositionOf(s, keys...)
+		return pos{file, line, col, ok}
+	}
+	y, err := UnmarshalYAML([]byte("a:\n  bee:   1\n"))
+	require.NoError(t, err)
+	j, err := UnmarshalJSON([]byte(`{"a": {"bee":   1}}`))
+	require.NoError(t, err)
+	assert.Equal(t, pos{"", 2, 3, true}, keyPosition(y, "a", "bee"))
+	assert.Equal(t, pos{"", 2, 10, true}, position(y, "a", "bee"))
+	assert.Equal(t, pos{"", 1, 8, true}, keyPosition(j, "a", "bee"))
+	assert.Equal(t, pos{"", 1, 17, true}, position(j, "a", "bee"))
+
+	m := NewMultiSource(WithLabel(j, "j.json"), NewPrefixSource(WithLabel(y, "y.yaml"), "p"))
+	assert.Equal(t, pos{"y.yaml", 2, 3, true}, keyPosition(m, "p", "a", "bee"))
+	assert.Equal(t, pos{"j.json", 1, 8, true}, keyPosition(m.Recurse("a"), "bee"))
+	assert.False(t, keyPosition(m, "a", "nope").ok)
+}
@@ -1,6 +1,8 @@
 package nflex
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 )
 
@@ -35,6 +37,32 @@ func (m prefixSource) Mutate(mutation Mutation) Source {
 	return n
 }
 
+func (m prefixSource) Label() string { return SourceLabel(m.source) }
+
+func (m prefixSource) Position(keys ...string) (string, int, int, bool) {
+	np, newKeys, mismatch := m.recurse(keys)
+	if mismatch || len(np) != 0 {
+		return "", 0, 0, false
+	}
+	return PositionOf(m.source, newKeys...)
+}
+
+func (m prefixSource) keyPosition(keys ...string) (string, int, int, bool) {
+	np, newKeys, mismatch := m.recurse(keys)
+	if mismatch || len(np) != 0 || len(newKeys) == 0 {
+		return "", 0, 0, false
+	}
+	return keyPositionOf(m.source, newKeys...)
+}
+
+func (m prefixSource) Tag(keys ...string) string {
+	np, newKeys, mismatch := m.recurse(keys)
+	if mismatch || len(np) != 0 {
+		return ""
+	}
+	return TagOf(m.source, newKeys...)
+}
+
 func (m prefixSource) recurse(keys []string) ([]string, []string, bool) {
 	np := m.prefix
 	for len(keys) > 0 && len(np) > 0 {
@@ -106,6 +134,25 @@ func (m prefixSource) GetInt(keys ...string) (int64, error) {
 	return 0, errors.Wrapf(ErrWrongType, "key %v is an object (not an integer)", keys)
 }
 
+func (m prefixSource) GetUInt(keys ...string) (uint64, error) {
+	np, newKeys, mismatch := m.recurse(keys)
+	if mismatch {
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", keys)
+	}
+	if len(np) == 0 {
+		return GetUInt(m.source, newKeys...)
+	}
+	return 0, errors.Wrapf(ErrWrongType, "key %v is an object (not an integer)", keys)
+}
+
+func (m prefixSource) getTime(keys ...string) (time.Time, bool) {
+	np, newKeys, mismatch := m.recurse(keys)
+	if mismatch || len(np) != 0 {
+		return time.Time{}, false
+	}
+	return nativeTime(m.source, newKeys...)
+}
+
 func (m prefixSource) GetFloat(keys ...string) (float64, error) {
 	np, newKeys, mismatch := m.recurse(keys)
 	if mismatch {
@@ -160,3 +207,25 @@ func (m prefixSource) Type(keys ...string) NodeType {
 	}
 	return Map
 }
+
+func (m prefixSource) Set(value interface{}, keys ...string) error {
+	np, newKeys, mismatch := m.recurse(keys)
+	if mismatch || len(np) != 0 {
+		return errors.Wrapf(ErrNotMutable, "key %v is not inside prefix %v", keys, m.prefix)
+	}
+	if s, ok := m.source.(MutableSource); ok {
+		return s.Set(value, newKeys...)
+	}
+	return errors.Wrapf(ErrNotMutable, "key %v", keys)
+}
+
+func (m prefixSource) Delete(keys ...string) error {
+	np, newKeys, mismatch := m.recurse(keys)
+	if mismatch || len(np) != 0 {
+		return errors.Wrapf(ErrNotMutable, "key %v is not inside prefix %v", keys, m.prefix)
+	}
+	if s, ok := m.source.(MutableSource); ok {
+		return s.Delete(newKeys...)
+	}
+	return errors.Wrapf(ErrNotMutable, "key %v", keys)
+}
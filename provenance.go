@@ -0,0 +1,70 @@
+package nflex
+
+// Origin is one source that has a value for a path
+type Origin struct {
+	// Label is the label of the source, see WithLabel.  Sources read
+	// with UnmarshalFile are labeled with their file name.
+	Label string
+	// Source is the source, already recursed to the path
+	Source Source
+}
+
+// Provenance describes which of the sources in a MultiSource provide
+// the value at a path
+type Provenance struct {
+	Path []string
+	// Winner is the source whose value is used for scalars
+	Winner Origin
+	// Shadowed are the other sources that have the path, in priority
+	// order.  For maps and slices that are combined, these sources
+	// also contribute to the combined value.
+	Shadowed []Origin
+}
+
+// Explain reports which sources provide the value at a path.
+// MultiSources nested inside the MultiSource are expanded so that
+// every Origin is a leaf source.  The bool is false if no source
+// has the path.
+func (m *MultiSource) Explain(keys ...string) (Provenance, bool) {
+	origins := m.origins(keys)
+	if len(origins) == 0 {
+		return Provenance{}, false
+	}
+	return Provenance{
+		Path:     keys,
+		Winner:   origins[0],
+		Shadowed: origins[1:],
+	}, true
+}
+
+// origins lists the sources for a path in priority order
+func (m *MultiSource) origins(keys []string) []Origin {
+	r := m.recurse(keys...)
+	if r == nil {
+		return nil
+	}
+	origins := make([]Origin, 0, len(r.sources))
+	add := func(source Source) {
+		if !source.Exists() {
+			return
+		}
+		if nested, ok := source.(*MultiSource); ok {
+			origins = append(origins, nested.origins(nil)...)
+			return
+		}
+		origins = append(origins, Origin{
+			Label:  SourceLabel(source),
+			Source: source,
+		})
+	}
+	if r.first {
+		for _, source := range r.sources {
+			add(source)
+		}
+	} else {
+		for i := len(r.sources) - 1; i >= 0; i-- {
+			add(r.sources[i])
+		}
+	}
+	return origins
+}
@@ -0,0 +1,88 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func originLabels(origins []Origin) []string {
+	labels := make([]string, len(origins))
+	for i, o := range origins {
+		labels[i] = o.Label
+	}
+	return labels
+}
+
+func TestExplain(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+	env := NewEnvSource("APP_", "__", WithEnviron([]string{"APP_A__B__S=env"}))
+	m := NewMultiSource(env, j, y)
+
+	p, ok := m.Explain("a", "b", "s")
+	require.True(t, ok)
+	assert.Equal(t, []string{"a", "b", "s"}, p.Path)
+	assert.Equal(t, "environment", p.Winner.Label)
+	assert.Equal(t, []string{"common.json", "common.yaml"}, originLabels(p.Shadowed))
+	assert.Equal(t, "env", getString(t, p.Winner.Source))
+
+	p, ok = m.Explain("a", "b", "d", "y")
+	require.True(t, ok)
+	assert.Equal(t, "common.yaml", p.Winner.Label)
+	assert.Empty(t, p.Shadowed)
+
+	p, ok = m.Explain("a", "b", "a", "3")
+	require.True(t, ok)
+	assert.Equal(t, "common.yaml", p.Winner.Label, "slice elements come from one source")
+
+	_, ok = m.Explain("a", "nope")
+	assert.False(t, ok)
+
+	last := MultiSourceSetFirst(false).Apply(m).(*MultiSource)
+	p, ok = last.Explain("a", "b", "s")
+	require.True(t, ok)
+	assert.Equal(t, "common.yaml", p.Winner.Label)
+	assert.Equal(t, []string{"common.json", "environment"}, originLabels(p.Shadowed))
+}
+
+func TestExplainNested(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+	inner := NewMultiSource(j, y)
+	outer := NewMultiSource(NewPrefixSource(inner.Recurse("a"), "a"), y)
+	p, ok := outer.Explain("a", "b", "i")
+	require.True(t, ok)
+	assert.Equal(t, "common.json", p.Winner.Label)
+	assert.Equal(t, []string{"common.yaml", "common.yaml"}, originLabels(p.Shadowed))
+
+	// an explicit label on a nested MultiSource is reported as is
+	outer = NewMultiSource(WithLabel(inner, "defaults"), y)
+	p, ok = outer.Explain("a", "b", "i")
+	require.True(t, ok)
+	assert.Equal(t, "defaults", p.Winner.Label)
+	assert.Equal(t, []string{"common.yaml"}, originLabels(p.Shadowed))
+}
+
+func TestWithLabel(t *testing.T) {
+	j, err := UnmarshalFile("common.json", WithFS(content))
+	require.NoError(t, err, "open common.json")
+	assert.Equal(t, "common.json", SourceLabel(j.Recurse("a", "b")))
+	assert.Equal(t, "x", SourceLabel(WithLabel(j, "x").Recurse("a")))
+	assert.Equal(t, "x", SourceLabel(WithOffset(WithLabel(j, "x"), 1)))
+	assert.Equal(t, "", SourceLabel(NewMultiSource(j)))
+
+	m := WithLabel(NewMultiSource(j), "multi")
+	assert.Equal(t, "multi", SourceLabel(m))
+	r := m.Recurse("a", "b")
+	assert.Equal(t, "multi", SourceLabel(r))
+	assert.Equal(t, "foo", getString(t, r, "s"))
+	assert.Nil(t, m.Recurse("nope"))
+	mutated := MultiSourceSetFirst(false).Apply(m)
+	assert.Equal(t, "multi", SourceLabel(mutated))
+}
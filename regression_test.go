@@ -48,6 +48,22 @@ func TestRegression02(t *testing.T) {
 	assert.True(t, n == nil, "recurse foo is nil")
 }
 
+func TestYAMLScalarDocument(t *testing.T) {
+	s, err := UnmarshalYAML([]byte("8080\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Int, s.Type())
+	i, err := s.GetInt()
+	require.NoError(t, err)
+	assert.Equal(t, int64(8080), i)
+	if r := s.Recurse(); assert.NotNil(t, r) {
+		assert.Equal(t, Int, r.Type())
+	}
+
+	s, err = UnmarshalYAML([]byte("a: 1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Map, s.Type())
+}
+
 func getLen(t *testing.T, s Source, args ...string) int {
 	l, err := s.Len(args...)
 	require.NoError(t, err, "getLen")
@@ -0,0 +1,502 @@
+package nflex
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Schema is a JSON Schema that can validate any Source.  It supports
+// this subset of draft 2020-12: type, enum, const, properties,
+// required, additionalProperties, items, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, pattern, allOf, anyOf, oneOf,
+// not, and $ref to "#" or a JSON pointer within the same document,
+// like "#/$defs/server".  Other keywords are ignored.  Patterns use
+// Go regular expression syntax.
+type Schema struct {
+	root     Source
+	patterns map[string]*regexp.Regexp
+	checked  map[string]bool // paths of subschemas that check has seen
+}
+
+// SchemaError is one way that a value does not match a Schema
+type SchemaError struct {
+	Path       []string // of the value, as for Recurse
+	SchemaPath []string // of the keyword in the schema
+	Message    string
+}
+
+func (e *SchemaError) Error() string { return fmt.Sprintf("%v: %s", e.Path, e.Message) }
+func (e *SchemaError) Unwrap() error { return ErrSchemaMismatch }
+
+// ValidationError is returned by Validate when a value does not
+// match a Schema.  It has every mismatch that was found.
+type ValidationError struct {
+	Errors []*SchemaError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, se := range e.Errors {
+		msgs[i] = se.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, se := range e.Errors {
+		errs[i] = se
+	}
+	return errs
+}
+
+// maxRefs limits how many times $ref can be followed without moving
+// to a child value.  It stops schemas that refer to themselves.
+const maxRefs = 32
+
+// NewSchema checks a JSON Schema that has been parsed into a
+// Source, so the schema can be JSON, YAML, or any other Source.
+//
+//	schemaSource, err := nflex.UnmarshalFile("config.schema.yaml")
+//	schema, err := nflex.NewSchema(schemaSource)
+//	err = schema.Validate(config)
+func NewSchema(schema Source) (*Schema, error) {
+	s := &Schema{
+		root:     schema,
+		patterns: make(map[string]*regexp.Regexp),
+		checked:  make(map[string]bool),
+	}
+	if err := s.check(nil); err != nil {
+		return nil, err
+	}
+	s.checked = nil
+	return s, nil
+}
+
+// check looks for problems in a schema and its subschemas, including
+// the targets of $ref, which can be anywhere in the document
+func (s *Schema) check(at []string) error {
+	seen := fmt.Sprintf("%q", at)
+	if s.checked[seen] {
+		return nil
+	}
+	s.checked[seen] = true
+	switch s.root.Type(at...) {
+	case Bool:
+		return nil
+	case Map:
+	default:
+		return errors.Errorf("schema %v must be an object or a boolean%s", at, positionSuffix(PositionOf(s.root, at...)))
+	}
+	keys, err := s.root.Keys(at...)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		p := combine(at, []string{key})
+		switch key {
+		case "type":
+			types := []string{}
+			if s.root.Type(p...) == Slice {
+				types, err = s.strings(p)
+			} else {
+				var t string
+				t, err = s.root.GetString(p...)
+				types = append(types, t)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+			for _, t := range types {
+				if _, ok := schemaTypes[t]; !ok {
+					return errors.Errorf("schema %v: unknown type '%s'", p, t)
+				}
+			}
+		case "pattern":
+			pattern, err := s.root.GetString(p...)
+			if err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+			s.patterns[pattern] = re
+		case "$ref":
+			ref, err := s.root.GetString(p...)
+			if err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+			target, err := refPath(ref)
+			if err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+			if !s.root.Exists(target...) {
+				return errors.Errorf("schema %v: '%s' does not exist", p, ref)
+			}
+			if err := s.check(target); err != nil {
+				return err
+			}
+		case "required":
+			if _, err := s.strings(p); err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if _, err := s.root.GetFloat(p...); err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+		case "enum":
+			if s.root.Type(p...) != Slice {
+				return errors.Errorf("schema %v must be an array", p)
+			}
+		case "items", "additionalProperties", "not":
+			if err := s.check(p); err != nil {
+				return err
+			}
+		case "properties", "$defs", "definitions":
+			names, err := s.root.Keys(p...)
+			if err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+			for _, name := range names {
+				if err := s.check(combine(p, []string{name})); err != nil {
+					return err
+				}
+			}
+		case "allOf", "anyOf", "oneOf":
+			length, err := s.root.Len(p...)
+			if err != nil {
+				return errors.Wrapf(err, "schema %v", p)
+			}
+			for i := 0; i < length; i++ {
+				if err := s.check(combine(p, []string{strconv.Itoa(i)})); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) strings(at []string) ([]string, error) {
+	length, err := s.root.Len(at...)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, length)
+	for i := range ret {
+		ret[i], err = s.root.GetString(combine(at, []string{strconv.Itoa(i)})...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// refPath turns a $ref into a path in the schema.  Only references
+// within the same document are supported.
+func refPath(ref string) ([]string, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, errors.Errorf("only references within the schema, like '#/$defs/x', are supported, not '%s'", ref)
+	}
+	fragment, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, err
+	}
+	return ParsePointer(fragment)
+}
+
+// ParsePointer turns a JSON Pointer (RFC 6901), like "/servers/0/name",
+// into a path of keys.  The empty pointer is the whole document.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, errors.Errorf("JSON pointer '%s' must start with /", pointer)
+	}
+	keys := strings.Split(pointer[1:], "/")
+	for i, key := range keys {
+		keys[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+	}
+	return keys, nil
+}
+
+var schemaTypes = map[string]func(Source, []string) bool{
+	"null":    func(s Source, p []string) bool { return s.Type(p...) == Nil },
+	"boolean": func(s Source, p []string) bool { return s.Type(p...) == Bool },
+	"object":  func(s Source, p []string) bool { return s.Type(p...) == Map },
+	"array":   func(s Source, p []string) bool { return s.Type(p...) == Slice },
+	"string":  func(s Source, p []string) bool { return s.Type(p...) == String },
+	"number": func(s Source, p []string) bool {
+		t := s.Type(p...)
+		return t == Int || t == Float
+	},
+	"integer": func(s Source, p []string) bool {
+		switch s.Type(p...) {
+		case Int:
+			return true
+		case Float:
+			f, err := s.GetFloat(p...)
+			return err == nil && f == math.Trunc(f) && !math.IsInf(f, 0)
+		default:
+			return false
+		}
+	},
+}
+
+// Validate checks that source matches the schema.  It returns a
+// *ValidationError with every mismatch.
+func (s *Schema) Validate(source Source) error {
+	v := validation{
+		schema: s,
+		source: source,
+	}
+	v.validate(nil, nil, 0)
+	if len(v.errors) != 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+type validation struct {
+	schema *Schema
+	source Source
+	errors []*SchemaError
+}
+
+func (v *validation) fail(at []string, path []string, format string, args ...interface{}) {
+	v.errors = append(v.errors, &SchemaError{
+		Path:       path,
+		SchemaPath: at,
+		Message:    fmt.Sprintf(format, args...) + positionSuffix(PositionOf(v.source, path...)),
+	})
+}
+
+// matches reports if the value at path matches the schema at at,
+// without keeping errors
+func (v *validation) matches(at []string, path []string, refs int) bool {
+	sub := validation{
+		schema: v.schema,
+		source: v.source,
+	}
+	sub.validate(at, path, refs)
+	return len(sub.errors) == 0
+}
+
+// validate checks the value at path against the schema at at
+func (v *validation) validate(at []string, path []string, refs int) {
+	root := v.schema.root
+	if root.Type(at...) == Bool {
+		if ok, _ := root.GetBool(at...); !ok {
+			v.fail(at, path, "no value is allowed")
+		}
+		return
+	}
+	keyword := func(k string) []string { return combine(at, []string{k}) }
+	has := func(k string) bool { return root.Exists(keyword(k)...) }
+
+	if has("type") {
+		v.validateType(keyword("type"), path)
+	}
+	if has("enum") {
+		p := keyword("enum")
+		length, _ := root.Len(p...)
+		found := false
+		for i := 0; i < length && !found; i++ {
+			found = sameValue(root, combine(p, []string{strconv.Itoa(i)}), v.source, path)
+		}
+		if !found {
+			v.fail(p, path, "value is not one of the allowed values")
+		}
+	}
+	if has("const") && !sameValue(root, keyword("const"), v.source, path) {
+		v.fail(keyword("const"), path, "value is not the allowed value")
+	}
+	switch v.source.Type(path...) {
+	case Int, Float:
+		v.validateNumber(at, path)
+	case String:
+		if has("pattern") {
+			pattern, _ := root.GetString(keyword("pattern")...)
+			str, err := v.source.GetString(path...)
+			if err == nil && !v.schema.patterns[pattern].MatchString(str) {
+				v.fail(keyword("pattern"), path, "'%s' does not match '%s'", str, pattern)
+			}
+		}
+	case Map:
+		v.validateObject(at, path)
+	case Slice:
+		if has("items") {
+			length, _ := v.source.Len(path...)
+			for i := 0; i < length; i++ {
+				v.validate(keyword("items"), combine(path, []string{strconv.Itoa(i)}), 0)
+			}
+		}
+	}
+	if has("allOf") {
+		length, _ := root.Len(keyword("allOf")...)
+		for i := 0; i < length; i++ {
+			v.validate(combine(keyword("allOf"), []string{strconv.Itoa(i)}), path, refs)
+		}
+	}
+	if has("anyOf") || has("oneOf") {
+		for _, k := range []string{"anyOf", "oneOf"} {
+			if !has(k) {
+				continue
+			}
+			length, _ := root.Len(keyword(k)...)
+			count := 0
+			for i := 0; i < length; i++ {
+				if v.matches(combine(keyword(k), []string{strconv.Itoa(i)}), path, refs) {
+					count++
+				}
+			}
+			switch {
+			case count == 0:
+				v.fail(keyword(k), path, "value does not match any of the schemas in %s", k)
+			case k == "oneOf" && count > 1:
+				v.fail(keyword(k), path, "value matches %d of the schemas in oneOf", count)
+			}
+		}
+	}
+	if has("not") && v.matches(keyword("not"), path, refs) {
+		v.fail(keyword("not"), path, "value matches a schema that it must not match")
+	}
+	if has("$ref") {
+		if refs >= maxRefs {
+			v.fail(keyword("$ref"), path, "too many $ref without a change of value")
+			return
+		}
+		ref, _ := root.GetString(keyword("$ref")...)
+		target, _ := refPath(ref)
+		v.validate(target, path, refs+1)
+	}
+}
+
+func (v *validation) validateType(at []string, path []string) {
+	root := v.schema.root
+	var types []string
+	if root.Type(at...) == Slice {
+		types, _ = v.schema.strings(at)
+	} else {
+		t, _ := root.GetString(at...)
+		types = []string{t}
+	}
+	for _, t := range types {
+		if schemaTypes[t](v.source, path) {
+			return
+		}
+	}
+	v.fail(at, path, "value is a %s, not %s", v.source.Type(path...), strings.Join(types, " or "))
+}
+
+func (v *validation) validateNumber(at []string, path []string) {
+	root := v.schema.root
+	f, err := v.source.GetFloat(path...)
+	if err != nil {
+		return
+	}
+	for _, check := range []struct {
+		keyword string
+		fails   func(f, limit float64) bool
+		message string
+	}{
+		{"minimum", func(f, limit float64) bool { return f < limit }, "less than"},
+		{"maximum", func(f, limit float64) bool { return f > limit }, "greater than"},
+		{"exclusiveMinimum", func(f, limit float64) bool { return f <= limit }, "not greater than"},
+		{"exclusiveMaximum", func(f, limit float64) bool { return f >= limit }, "not less than"},
+	} {
+		p := combine(at, []string{check.keyword})
+		if !root.Exists(p...) {
+			continue
+		}
+		limit, err := root.GetFloat(p...)
+		if err == nil && check.fails(f, limit) {
+			v.fail(p, path, "%s is %s %s", formatNumber(f), check.message, formatNumber(limit))
+		}
+	}
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (v *validation) validateObject(at []string, path []string) {
+	root := v.schema.root
+	propertiesAt := combine(at, []string{"properties"})
+	if root.Exists(combine(at, []string{"required"})...) {
+		required, _ := v.schema.strings(combine(at, []string{"required"}))
+		for _, name := range required {
+			if !v.source.Exists(combine(path, []string{name})...) {
+				v.fail(combine(at, []string{"required"}), combine(path, []string{name}), "required property is missing")
+			}
+		}
+	}
+	keys, err := v.source.Keys(path...)
+	if err != nil {
+		return
+	}
+	additionalAt := combine(at, []string{"additionalProperties"})
+	hasAdditional := root.Exists(additionalAt...)
+	for _, key := range keys {
+		p := combine(path, []string{key})
+		if root.Exists(combine(propertiesAt, []string{key})...) {
+			v.validate(combine(propertiesAt, []string{key}), p, 0)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if root.Type(additionalAt...) == Bool {
+			if ok, _ := root.GetBool(additionalAt...); !ok {
+				v.fail(additionalAt, p, "property is not allowed")
+			}
+			continue
+		}
+		v.validate(additionalAt, p, 0)
+	}
+}
+
+// sameValue compares values from two sources the way JSON Schema
+// does: numbers are equal if they have the same value, even if one
+// is an integer and the other is not.
+func sameValue(a Source, aPath []string, b Source, bPath []string) bool {
+	av, err := ToInterface(a, aPath...)
+	if err != nil {
+		return false
+	}
+	bv, err := ToInterface(b, bPath...)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(numbersAsFloats(av), numbersAsFloats(bv))
+}
+
+func numbersAsFloats(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int64:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = numbersAsFloats(e)
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = numbersAsFloats(e)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,207 @@
+package nflex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchemaYAML = `
+$defs:
+  port:
+    type: integer
+    minimum: 1
+    maximum: 65535
+  server:
+    type: object
+    required: [host]
+    properties:
+      host:
+        type: string
+        pattern: '^[a-z0-9.-]+$'
+      port:
+        $ref: '#/$defs/port'
+      tags:
+        type: array
+        items:
+          type: string
+    additionalProperties: false
+type: object
+required: [name, servers]
+properties:
+  name:
+    type: string
+  level:
+    enum: [debug, info, warn]
+  ratio:
+    type: number
+    exclusiveMinimum: 0
+    exclusiveMaximum: 1
+  servers:
+    type: array
+    items:
+      $ref: '#/$defs/server'
+  timeout:
+    anyOf:
+      - type: integer
+      - type: string
+        pattern: '^[0-9]+s$'
+  mode:
+    oneOf:
+      - const: fast
+      - type: string
+        pattern: '^f'
+  nullable:
+    type: [string, "null"]
+  weird~/key:
+    not:
+      type: boolean
+additionalProperties:
+  type: string
+`
+
+func TestSchemaValidate(t *testing.T) {
+	schemaSource, err := UnmarshalYAML([]byte(testSchemaYAML))
+	require.NoError(t, err)
+	schema, err := NewSchema(schemaSource)
+	require.NoError(t, err)
+
+	good, err := UnmarshalJSON([]byte(`{
+		"name": "app",
+		"level": "info",
+		"ratio": 0.5,
+		"servers": [{"host": "a.example.com", "port": 80, "tags": ["x"]}],
+		"timeout": "30s",
+		"mode": "full",
+		"nullable": null,
+		"weird~/key": 7,
+		"extra": "ok"
+	}`))
+	require.NoError(t, err)
+	assert.NoError(t, schema.Validate(good))
+
+	base, err := UnmarshalYAML([]byte(`
+name: app
+servers:
+  - host: a.example.com
+    port: 80.0
+`))
+	require.NoError(t, err)
+	override, err := UnmarshalYAML([]byte(`
+level: trace
+ratio: 1
+servers:
+  - host: Bad_Host
+    port: 70000
+    tags: [x, 3]
+    color: red
+  - port: 22
+timeout: 30m
+mode: fast
+nullable: 3
+weird~/key: true
+extra: 5
+`))
+	require.NoError(t, err)
+	bad := NewMultiSource(WithLabel(override, "override.yaml"), base)
+
+	err = schema.Validate(bad)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrSchemaMismatch)
+	var ve *ValidationError
+	require.True(t, errors.As(err, &ve))
+
+	byPath := make(map[string][]*SchemaError)
+	for _, se := range ve.Errors {
+		byPath[strings.Join(se.Path, "/")] = append(byPath[strings.Join(se.Path, "/")], se)
+	}
+	for _, path := range []string{
+		"level",
+		"ratio",
+		"servers/0/host",
+		"servers/0/port",
+		"servers/0/tags/1",
+		"servers/0/color",
+		"servers/1/host",
+		"timeout",
+		"mode",
+		"nullable",
+		"weird~/key",
+		"extra",
+	} {
+		assert.Len(t, byPath[path], 1, path)
+	}
+	assert.Len(t, ve.Errors, 12)
+	assert.Equal(t, []string{"$defs", "server", "required"}, byPath["servers/1/host"][0].SchemaPath)
+	assert.Equal(t, []string{"$defs", "port", "maximum"}, byPath["servers/0/port"][0].SchemaPath)
+	assert.Contains(t, byPath["servers/0/port"][0].Message, "override.yaml:6:11")
+	assert.Contains(t, byPath["mode"][0].Message, "matches 2 of the schemas in oneOf")
+	assert.Equal(t, []string{"servers", "0", "port"}, byPath["servers/0/port"][0].Path)
+	assert.True(t, bad.Exists(byPath["servers/0/color"][0].Path...))
+
+	// 80.0 is an integer, as far as JSON Schema is concerned
+	port, err := NewSchema(schemaSource.Recurse("$defs", "port"))
+	require.NoError(t, err)
+	assert.NoError(t, port.Validate(base.Recurse("servers", "0", "port")))
+}
+
+func TestSchemaSpecial(t *testing.T) {
+	mustSchema := func(doc string) *Schema {
+		s, err := UnmarshalJSON([]byte(doc))
+		require.NoError(t, err, doc)
+		schema, err := NewSchema(s)
+		require.NoError(t, err, doc)
+		return schema
+	}
+	value := func(doc string) Source {
+		s, err := UnmarshalJSON([]byte(doc))
+		require.NoError(t, err, doc)
+		return s
+	}
+
+	assert.NoError(t, mustSchema(`true`).Validate(value(`{"a":1}`)))
+	assert.ErrorIs(t, mustSchema(`false`).Validate(value(`1`)), ErrSchemaMismatch)
+	assert.NoError(t, mustSchema(`{"enum":[1,[2,{"x":3}]]}`).Validate(value(`[2.0,{"x":3}]`)))
+	assert.ErrorIs(t, mustSchema(`{"enum":[1,[2,{"x":3}]]}`).Validate(value(`[2,{"x":4}]`)), ErrSchemaMismatch)
+
+	loop := mustSchema(`{"$defs":{"a":{"$ref":"#/$defs/b"},"b":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`)
+	assert.ErrorIs(t, loop.Validate(value(`1`)), ErrSchemaMismatch)
+
+	tree := mustSchema(`{"type":"object","properties":{"kids":{"type":"array","items":{"$ref":"#"}}},"additionalProperties":{"type":"integer"}}`)
+	assert.NoError(t, tree.Validate(value(`{"kids":[{"kids":[{"n":1}]}]}`)))
+	assert.ErrorIs(t, tree.Validate(value(`{"kids":[{"kids":[{"n":"x"}]}]}`)), ErrSchemaMismatch)
+
+	components := mustSchema(`{"components":{"host":{"type":"string","pattern":"^[a-z]+$"}},"properties":{"host":{"$ref":"#/components/host"}}}`)
+	assert.NoError(t, components.Validate(value(`{"host":"db"}`)))
+	assert.ErrorIs(t, components.Validate(value(`{"host":"DB-1"}`)), ErrSchemaMismatch)
+
+	for _, doc := range []string{
+		`3`,
+		`{"type":"int"}`,
+		`{"pattern":"("}`,
+		`{"x":{"pattern":"("},"$ref":"#/x"}`,
+		`{"$ref":"#/$defs/missing"}`,
+		`{"$ref":"other.json#/x"}`,
+		`{"properties":{"a":3}}`,
+		`{"minimum":"x"}`,
+	} {
+		s, err := UnmarshalJSON([]byte(doc))
+		require.NoError(t, err, doc)
+		_, err = NewSchema(s)
+		assert.Error(t, err, doc)
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	keys, err := ParsePointer("/a~1b/m~0n/0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/b", "m~n", "0"}, keys)
+	keys, err = ParsePointer("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, keys)
+	_, err = ParsePointer("a")
+	assert.Error(t, err)
+}
@@ -0,0 +1,367 @@
+package nflex
+
+import (
+	"encoding"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StructSchema creates a JSON Schema (draft 2020-12) that describes
+// what Decode reads into a struct, or a pointer to a struct.  The
+// schema is a Source so it can be written out with MarshalJSON or
+// MarshalYAML and used with NewSchema.
+//
+//	schema, err := nflex.StructSchema(Config{})
+//	enc, err := nflex.MarshalJSON(schema)
+//
+// Properties are named by the same `nflex` tags that Decode uses.
+// Fields tagged "required" are required.  "default=" values are
+// decoded into the field, as Decode does, and written as the schema's
+// default.  A `doc` tag is the description:
+//
+//	Port int `nflex:"port,default=8080" doc:"port to listen on"`
+//
+// Structs are objects.  Maps are objects whose values all have the
+// same schema.  Slices and arrays are arrays.  Integers have the
+// minimum and maximum of their size.  Pointers, maps, and slices can
+// also be null.  time.Duration, time.Time, and url.URL are strings.
+// Types that implement encoding.TextUnmarshaler are strings or the
+// JSON type of their kind.  Empty interfaces can be any value.
+// Structs that contain themselves are put in $defs and referred to
+// with $ref.  Types that Decode only handles with WithDecodeHook are
+// an error.
+//
+// The schema does not have "additionalProperties": false because
+// Decode ignores unknown keys unless WithStrict is used.
+func StructSchema(model interface{}) (Source, error) {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.Wrapf(ErrWrongType, "StructSchema needs a struct, not %T", model)
+	}
+	g := schemaGenerator{
+		root:   t,
+		active: make(map[reflect.Type]bool),
+		refs:   make(map[reflect.Type]string),
+		defs:   newOrderedMap(),
+	}
+	root, err := g.schema(t, nil)
+	if err != nil {
+		return nil, err
+	}
+	m := newOrderedMap()
+	m.set("$schema", "https://json-schema.org/draft/2020-12/schema")
+	for _, k := range root.keys {
+		m.set(k, root.values[k])
+	}
+	if len(g.defs.keys) != 0 {
+		m.set("$defs", g.defs)
+	}
+	p := valueSource{
+		value:   m,
+		debugID: debugID(),
+	}
+	debug("nflex/StructSchema", p.debugID, p.debugKeys)
+	return p, nil
+}
+
+type schemaGenerator struct {
+	root   reflect.Type
+	active map[reflect.Type]bool   // structs that are being generated
+	refs   map[reflect.Type]string // structs that contain themselves
+	defs   *orderedMap
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{
+		values: make(map[string]interface{}),
+	}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// typeSchema is a schema with just a type, or a list of types
+func typeSchema(types ...string) *orderedMap {
+	m := newOrderedMap()
+	if len(types) == 1 {
+		m.set("type", types[0])
+		return m
+	}
+	list := make([]interface{}, len(types))
+	for i, typ := range types {
+		list[i] = typ
+	}
+	m.set("type", list)
+	return m
+}
+
+// schema describes a type.  path is used for errors.
+func (g *schemaGenerator) schema(t reflect.Type, path []string) (*orderedMap, error) {
+	switch t {
+	case durationType, timeType:
+		return typeSchema("string"), nil
+	case urlType:
+		m := typeSchema("string")
+		m.set("format", "uri-reference")
+		return m, nil
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return textSchema(t), nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		e, err := g.schema(t.Elem(), path)
+		if err != nil {
+			return nil, err
+		}
+		return nullable(e), nil
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return nil, errors.Errorf("key %v: cannot describe non-empty interface %s", path, t)
+		}
+		return newOrderedMap(), nil
+	case reflect.Bool:
+		return typeSchema("boolean"), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		m := typeSchema("integer")
+		m.set("minimum", int64(-1)<<(t.Bits()-1))
+		m.set("maximum", int64(1<<(t.Bits()-1)-1))
+		return m, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		m := typeSchema("integer")
+		m.set("minimum", int64(0))
+		if t.Bits() == 64 {
+			m.set("maximum", uint64(math.MaxUint64))
+		} else {
+			m.set("maximum", int64(1)<<t.Bits()-1)
+		}
+		return m, nil
+	case reflect.Float32, reflect.Float64:
+		return typeSchema("number"), nil
+	case reflect.String:
+		return typeSchema("string"), nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, errors.Wrapf(ErrWrongType, "key %v is a %s: map keys must be strings", path, t)
+		}
+		e, err := g.schema(t.Elem(), combine(path, []string{"*"}))
+		if err != nil {
+			return nil, err
+		}
+		m := typeSchema("object", "null")
+		m.set("additionalProperties", e)
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		e, err := g.schema(t.Elem(), combine(path, []string{"*"}))
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind() == reflect.Slice {
+			m := typeSchema("array", "null")
+			m.set("items", e)
+			return m, nil
+		}
+		m := typeSchema("array")
+		m.set("items", e)
+		m.set("maxItems", int64(t.Len()))
+		return m, nil
+	case reflect.Struct:
+		return g.structSchema(t, path)
+	default:
+		return nil, errors.Wrapf(ErrWrongType, "key %v is a %s which cannot be decoded", path, t)
+	}
+}
+
+// structSchema describes a struct.  Structs that contain themselves
+// are described once, in $defs, or at the root.
+func (g *schemaGenerator) structSchema(t reflect.Type, path []string) (*orderedMap, error) {
+	if g.active[t] {
+		if _, ok := g.refs[t]; !ok {
+			g.refs[t] = g.defName(t)
+		}
+		return refSchema(g.refs[t]), nil
+	}
+	g.active[t] = true
+	defer delete(g.active, t)
+
+	m := typeSchema("object")
+	properties := newOrderedMap()
+	isRequired := newOrderedMap()
+	if err := g.fields(t, path, properties, isRequired, false); err != nil {
+		return nil, err
+	}
+	m.set("properties", properties)
+	var required []interface{}
+	for _, name := range isRequired.keys {
+		if isRequired.values[name] == true {
+			required = append(required, name)
+		}
+	}
+	if len(required) != 0 {
+		m.set("required", required)
+	}
+	ref, ok := g.refs[t]
+	if !ok || t == g.root {
+		return m, nil
+	}
+	g.defs.set(strings.TrimPrefix(ref, "#/$defs/"), m)
+	return refSchema(ref), nil
+}
+
+// textSchema describes a type that implements
+// encoding.TextUnmarshaler.  Decode gives it the text of any scalar.
+// It can also be the JSON type that NewStructSource writes for its
+// kind.
+func textSchema(t reflect.Type) *orderedMap {
+	switch t.Kind() {
+	case reflect.Bool:
+		return typeSchema("string", "boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return typeSchema("string", "integer")
+	case reflect.Float32, reflect.Float64:
+		return typeSchema("string", "number")
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		return typeSchema("string", "null")
+	default:
+		return typeSchema("string")
+	}
+}
+
+// nullable lets a schema match null too.  Decode reads null into
+// pointers, maps, and slices as nil.
+func nullable(m *orderedMap) *orderedMap {
+	switch t := m.values["type"].(type) {
+	case string:
+		m.set("type", []interface{}{t, "null"})
+		return m
+	case []interface{}:
+		for _, e := range t {
+			if e == "null" {
+				return m
+			}
+		}
+		m.set("type", append(t, "null"))
+		return m
+	}
+	if len(m.keys) == 0 {
+		// already matches anything
+		return m
+	}
+	n := newOrderedMap()
+	n.set("anyOf", []interface{}{m, typeSchema("null")})
+	return n
+}
+
+func refSchema(ref string) *orderedMap {
+	m := newOrderedMap()
+	m.set("$ref", ref)
+	return m
+}
+
+// defName picks the $ref for a struct that contains itself
+func (g *schemaGenerator) defName(t reflect.Type) string {
+	if t == g.root {
+		return "#"
+	}
+	base := t.Name()
+	if base == "" {
+		base = "struct"
+	}
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := g.defs.values[name]; !ok && !g.refUsed("#/$defs/"+name) {
+			return "#/$defs/" + name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+func (g *schemaGenerator) refUsed(ref string) bool {
+	for _, r := range g.refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// fields adds the fields of a struct to properties and records in
+// required whether each one is required.  Fields of embedded structs
+// do not replace fields of the outer struct.
+func (g *schemaGenerator) fields(t reflect.Type, path []string, properties *orderedMap, required *orderedMap, embedded bool) error {
+	for _, field := range structFields(t) {
+		f := t.Field(field.index)
+		if field.inline {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if err := g.fields(ft, path, properties, required, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, exists := properties.values[field.name]; exists && embedded {
+			continue
+		}
+		fieldPath := combine(path, []string{field.name})
+		s, err := g.schema(f.Type, fieldPath)
+		if err != nil {
+			return err
+		}
+		if doc := f.Tag.Get("doc"); doc != "" {
+			s.set("description", doc)
+		}
+		if field.hasDefault {
+			d, err := schemaDefault(f.Type, field.defaultValue, fieldPath)
+			if err != nil {
+				return err
+			}
+			s.set("default", d)
+		}
+		properties.set(field.name, s)
+		required.set(field.name, field.required && !field.hasDefault)
+	}
+	return nil
+}
+
+// schemaDefault converts the text of a "default=" option into
+// a value for the schema by decoding it into the field's type, as
+// Decode does, so that "default=1.10" for a string is "1.10"
+func schemaDefault(t reflect.Type, text string, path []string) (interface{}, error) {
+	v := reflect.New(t).Elem()
+	var d decoder
+	d.decodeDefault(path, v, text)
+	if len(d.errors) != 0 {
+		return nil, &DecodeError{Errors: d.errors}
+	}
+	switch {
+	case t == durationType, t == timeType:
+	case t == urlType:
+		u := v.Addr().Interface().(*url.URL)
+		return u.String(), nil
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		if m, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+			b, err := m.MarshalText()
+			if err != nil {
+				return nil, errors.Wrapf(err, "key %v default", path)
+			}
+			return string(b), nil
+		}
+		return strings.TrimSpace(text), nil
+	}
+	return normalizeValue(v, path)
+}
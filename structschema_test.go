@@ -0,0 +1,173 @@
+package nflex
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSchemaServer struct {
+	Host string `nflex:"host,required" doc:"host name"`
+	Port uint16 `nflex:"port,default=8080"`
+}
+
+type testSchemaConfig struct {
+	testEmbedded
+	Name     string                      `nflex:"service"`
+	Timeout  time.Duration               `nflex:"timeout,default=30s"`
+	Servers  []testSchemaServer          `nflex:"servers,required"`
+	Limits   map[string]float64          `nflex:"limits"`
+	Level    testLevel                   `nflex:"level"`
+	Pair     [2]bool                     `nflex:"pair"`
+	Tags     []string                    `nflex:"tags,default=[a, b]"`
+	Extra    interface{}                 `nflex:"extra"`
+	Backup   *testSchemaServer           `nflex:"backup,omitempty"`
+	Children map[string]*testSchemaChild `nflex:"children"`
+	Ignored  string                      `nflex:"-"`
+}
+
+type testSchemaChild struct {
+	Weight   int                `nflex:"weight"`
+	Children []*testSchemaChild `nflex:"children"`
+}
+
+func TestStructSchema(t *testing.T) {
+	schema, err := StructSchema(&testSchemaConfig{})
+	require.NoError(t, err)
+
+	got, err := MarshalJSON(schema)
+	require.NoError(t, err)
+	assert.Equal(t, `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{`+
+		`"name":{"type":"string"},`+
+		`"service":{"type":"string"},`+
+		`"timeout":{"type":"string","default":"30s"},`+
+		`"servers":{"type":["array","null"],"items":{"type":"object","properties":{`+
+		`"host":{"type":"string","description":"host name"},`+
+		`"port":{"type":"integer","minimum":0,"maximum":65535,"default":8080}},"required":["host"]}},`+
+		`"limits":{"type":["object","null"],"additionalProperties":{"type":"number"}},`+
+		`"level":{"type":["string","integer"]},`+
+		`"pair":{"type":"array","items":{"type":"boolean"},"maxItems":2},`+
+		`"tags":{"type":["array","null"],"items":{"type":"string"},"default":["a","b"]},`+
+		`"extra":{},`+
+		`"backup":{"type":["object","null"],"properties":{`+
+		`"host":{"type":"string","description":"host name"},`+
+		`"port":{"type":"integer","minimum":0,"maximum":65535,"default":8080}},"required":["host"]},`+
+		`"children":{"type":["object","null"],"additionalProperties":{"anyOf":[{"$ref":"#/$defs/testSchemaChild"},{"type":"null"}]}}},`+
+		`"required":["servers"],`+
+		`"$defs":{"testSchemaChild":{"type":"object","properties":{`+
+		`"weight":{"type":"integer","minimum":-9223372036854775808,"maximum":9223372036854775807},`+
+		`"children":{"type":["array","null"],"items":{"anyOf":[{"$ref":"#/$defs/testSchemaChild"},{"type":"null"}]}}}}}}`, string(got))
+
+	_, err = MarshalYAML(schema)
+	require.NoError(t, err)
+
+	validator, err := NewSchema(schema)
+	require.NoError(t, err)
+	good, err := UnmarshalYAML([]byte(`
+service: api
+timeout: 1m
+servers:
+  - host: a
+    port: 443
+children:
+  x:
+    weight: 1
+    children:
+      - weight: 2
+`))
+	require.NoError(t, err)
+	assert.NoError(t, validator.Validate(good))
+
+	bad, err := UnmarshalYAML([]byte(`
+servers:
+  - port: -1
+children:
+  x:
+    children:
+      - weight: heavy
+`))
+	require.NoError(t, err)
+	err = validator.Validate(bad)
+	assert.ErrorIs(t, err, ErrSchemaMismatch)
+	assert.Len(t, err.(*ValidationError).Errors, 3, err.Error())
+
+	zero, err := NewStructSource(testSchemaConfig{})
+	require.NoError(t, err)
+	assert.NoError(t, validator.Validate(zero), "zero value matches its own schema")
+	withNulls, err := UnmarshalYAML([]byte("servers: []\nbackup: null\nlimits: ~\nchildren: {x: null}\n"))
+	require.NoError(t, err)
+	assert.NoError(t, validator.Validate(withNulls))
+
+	type recursive struct {
+		Name string       `nflex:"name"`
+		Next *recursive   `nflex:"next"`
+		All  []*recursive `nflex:"all"`
+	}
+	schema, err = StructSchema(recursive{})
+	require.NoError(t, err)
+	got, err = MarshalJSON(schema)
+	require.NoError(t, err)
+	assert.Equal(t, `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{`+
+		`"name":{"type":"string"},"next":{"anyOf":[{"$ref":"#"},{"type":"null"}]},`+
+		`"all":{"type":["array","null"],"items":{"anyOf":[{"$ref":"#"},{"type":"null"}]}}}}`, string(got))
+
+	_, err = StructSchema(struct {
+		Bad int `nflex:"bad,default=x"`
+	}{})
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	_, err = StructSchema(3)
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = StructSchema(struct{ C chan int }{})
+	assert.ErrorIs(t, err, ErrWrongType)
+}
+
+type testSchemaBase struct {
+	ID    string `nflex:"id,required"`
+	Owner string `nflex:"owner"`
+}
+
+func TestStructSchemaFields(t *testing.T) {
+	var model struct {
+		testSchemaBase
+		ID      string `nflex:"id"`
+		Owner   string `nflex:"owner,required"`
+		Ver     string `nflex:"ver,default=1.10"`
+		Small   uint8  `nflex:"small"`
+		Signed  int8   `nflex:"signed"`
+		Big     uint64 `nflex:"big"`
+		Address string `nflex:"address,required"`
+	}
+	schema, err := StructSchema(model)
+	require.NoError(t, err)
+	assert.Equal(t, "1.10", getString(t, schema, "properties", "ver", "default"))
+	required, err := ToInterface(schema, "required")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"owner", "address"}, required, "required comes from the field that wins, once")
+	big, err := GetUInt(schema, "properties", "big", "maximum")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), big)
+
+	validator, err := NewSchema(schema)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		doc string
+		ok  bool
+	}{
+		{`{"owner":"a","address":"b","small":255,"signed":-128}`, true},
+		{`{"owner":"a","address":"b","small":300}`, false},
+		{`{"owner":"a","address":"b","signed":128}`, false},
+		{`{"owner":"a","address":"b","big":-1}`, false},
+		{`{"id":"a","address":"b"}`, false},
+	} {
+		err := validator.Validate(mustJSON(t, tc.doc))
+		if tc.ok {
+			assert.NoError(t, err, tc.doc)
+		} else {
+			assert.ErrorIs(t, err, ErrSchemaMismatch, tc.doc)
+		}
+	}
+}
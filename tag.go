@@ -0,0 +1,24 @@
+package nflex
+
+// Tagged is implemented by sources that know the tags of their
+// values.  Tag returns the tag that is written in the document, like
+// "!secret" or "!!str", or "" if the value has no explicit tag.
+// Applications can use tags for their own purposes:
+//
+//	if nflex.TagOf(source, "db", "password") == "!secret" {
+//		...
+//	}
+type Tagged interface {
+	Tag(keys ...string) string
+}
+
+// TagOf returns the tag of a value or "" if the source does not
+// have tags
+func TagOf(source Source, keys ...string) string {
+	if t, ok := source.(Tagged); ok {
+		return t.Tag(keys...)
+	}
+	return ""
+}
+
+var _ Tagged = parsedYAML{}
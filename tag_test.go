@@ -0,0 +1,139 @@
+package nflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const taggedYAML = `
+plain: 123
+quoted: "123"
+single: '123'
+str: !!str 123
+float: !!float 1
+int: !!int "12"
+bool: !!bool yes
+notbool: yes
+tilde: ~
+empty:
+null: !!null
+secret: !secret hunter2
+literal: |
+  true
+creds: !vault
+  path: db/creds
+`
+
+func TestYAMLTags(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(taggedYAML))
+	require.NoError(t, err)
+
+	cases := []struct {
+		key  string
+		want NodeType
+		tag  string
+	}{
+		{key: "plain", want: Int},
+		{key: "quoted", want: String},
+		{key: "single", want: String},
+		{key: "str", want: String, tag: "!!str"},
+		{key: "float", want: Float, tag: "!!float"},
+		{key: "int", want: Int, tag: "!!int"},
+		{key: "bool", want: Bool, tag: "!!bool"},
+		{key: "notbool", want: String},
+		{key: "tilde", want: Nil},
+		{key: "empty", want: Nil},
+		{key: "null", want: Nil, tag: "!!null"},
+		{key: "secret", want: String, tag: "!secret"},
+		{key: "literal", want: String},
+		{key: "creds", want: Map, tag: "!vault"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, s.Type(tc.key), "type of %s", tc.key)
+		assert.Equal(t, tc.tag, TagOf(s, tc.key), "tag of %s", tc.key)
+	}
+
+	// the getters convert, as they do for JSON strings
+	q, err := s.GetInt("quoted")
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), q)
+	str, err := s.GetString("quoted")
+	require.NoError(t, err)
+	assert.Equal(t, "123", str)
+
+	f, err := s.GetFloat("float")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, f)
+
+	i, err := s.GetInt("int")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), i)
+
+	b, err := s.GetBool("bool")
+	require.NoError(t, err)
+	assert.True(t, b)
+	_, err = s.GetBool("notbool")
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	// but not past an explicit tag for another type
+	_, err = s.GetInt("str")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = GetUInt(s, "str")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.GetInt("float")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = GetUInt(s, "float")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.GetFloat("str")
+	assert.ErrorIs(t, err, ErrWrongType)
+	tagged, err := UnmarshalYAML([]byte("b: !!bool true\ni: !!int 1\ns: !!str true\nx: !secret 7\n"))
+	require.NoError(t, err)
+	_, err = tagged.GetInt("b")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = tagged.GetBool("i")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = tagged.GetBool("s")
+	assert.ErrorIs(t, err, ErrWrongType)
+	x, err := tagged.GetInt("x")
+	require.NoError(t, err, "custom tags do not change the type")
+	assert.Equal(t, int64(7), x)
+
+	str, err = s.GetString("tilde")
+	require.NoError(t, err)
+	assert.Equal(t, "~", str)
+	keys, err := s.Keys("empty")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.Equal(t, 0, getLen(t, s, "tilde"))
+
+	got, err := MarshalJSON(s)
+	require.NoError(t, err)
+	assert.Equal(t, `{"plain":123,"quoted":"123","single":"123","str":"123","float":1.0,"int":12,"bool":true,`+
+		`"notbool":"yes","tilde":null,"empty":null,"null":null,"secret":"hunter2","literal":"true\n","creds":{"path":"db/creds"}}`, string(got))
+
+	wrapped := NewMultiSource(NewPrefixSource(WithLabel(s, "tagged.yaml"), "app"), NewEnvSource("X_", "_", WithEnviron([]string{})))
+	assert.Equal(t, "!secret", TagOf(wrapped, "app", "secret"))
+	assert.Equal(t, "!vault", TagOf(wrapped.Recurse("app"), "creds"))
+	assert.Equal(t, "", TagOf(wrapped, "app", "plain"))
+}
+
+func TestYAMLQuotedDecode(t *testing.T) {
+	type target struct {
+		Port  int
+		Ratio float64
+		On    bool
+		Name  string
+	}
+	y, err := UnmarshalYAML([]byte(`{port: "8080", ratio: '0.5', on: "true", name: ~}`))
+	require.NoError(t, err)
+	j, err := UnmarshalJSON([]byte(`{"port": "8080", "ratio": 0.5, "on": true, "name": null}`))
+	require.NoError(t, err)
+	for _, s := range []Source{y, j} {
+		got := target{Name: "x"}
+		require.NoError(t, Decode(s, &got))
+		assert.Equal(t, target{Port: 8080, Ratio: 0.5, On: true}, got)
+	}
+	assert.Equal(t, String, y.Type("port"))
+}
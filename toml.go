@@ -0,0 +1,97 @@
+package nflex
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// UnmarshalTOML parses a TOML document.  Integers are Int, floats
+// are Float, and datetimes are String: GetString returns them in
+// the same format they would be written in TOML.
+func UnmarshalTOML(data []byte) (Source, error) {
+	var raw map[string]interface{}
+	md, err := toml.Decode(string(data), &raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "toml")
+	}
+	// Go maps lose the order of keys so recover it from the metadata.
+	// Array indexes are not part of metadata keys so all tables in an
+	// array of tables share one ordering.
+	order := make(map[string]map[string]int)
+	for _, key := range md.Keys() {
+		parent := strings.Join(key[:len(key)-1], "\x00")
+		if order[parent] == nil {
+			order[parent] = make(map[string]int)
+		}
+		if _, ok := order[parent][key[len(key)-1]]; !ok {
+			order[parent][key[len(key)-1]] = len(order[parent])
+		}
+	}
+	p := valueSource{
+		value:   normalizeTOML(raw, nil, order),
+		debugID: debugID(),
+	}
+	debug("nflex/UnmarshalTOML", p.debugID, p.debugKeys)
+	return p, nil
+}
+
+func normalizeTOML(v interface{}, path []string, order map[string]map[string]int) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := &orderedMap{
+			keys:   make([]string, 0, len(t)),
+			values: make(map[string]interface{}, len(t)),
+		}
+		for k, e := range t {
+			m.keys = append(m.keys, k)
+			m.values[k] = normalizeTOML(e, combine(path, []string{k}), order)
+		}
+		positions := order[strings.Join(path, "\x00")]
+		sort.Slice(m.keys, func(i, j int) bool {
+			pi, iok := positions[m.keys[i]]
+			pj, jok := positions[m.keys[j]]
+			switch {
+			case iok && jok:
+				return pi < pj
+			case iok != jok:
+				return iok
+			default:
+				return m.keys[i] < m.keys[j]
+			}
+		})
+		return m
+	case []map[string]interface{}:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = normalizeTOML(e, path, order)
+		}
+		return a
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = normalizeTOML(e, path, order)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+// formatTime renders a time the way TOML would: local dates, times,
+// and datetimes have no offset.
+func formatTime(t time.Time) string {
+	switch t.Location().String() {
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
@@ -0,0 +1,92 @@
+package nflex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tomlDoc = `
+title = "example"
+count = -3
+ratio = 1.0
+when = 2026-01-02T03:04:05Z
+day = 2026-01-02
+clock = 07:32:00
+
+[[servers]]
+name = "alpha"
+port = 80
+
+[[servers]]
+port = 81
+name = "beta"
+`
+
+func TestTOMLTypes(t *testing.T) {
+	s, err := UnmarshalTOML([]byte(tomlDoc))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"title", "count", "ratio", "when", "day", "clock", "servers"}, mustKeys(t, s))
+	assert.Equal(t, Int, s.Type("count"))
+	assert.Equal(t, Float, s.Type("ratio"))
+	assert.Equal(t, String, s.Type("when"))
+	assert.Equal(t, Slice, s.Type("servers"))
+	assert.Equal(t, Map, s.Type("servers", "1"))
+	assert.Equal(t, Undefined, s.Type("servers", "2"))
+
+	i, err := s.GetInt("count")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-3), i)
+	f, err := s.GetFloat("count")
+	require.NoError(t, err)
+	assert.Equal(t, -3.0, f)
+	_, err = s.GetInt("ratio")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.GetString("count")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.GetBool("missing")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+
+	when, err := s.GetString("when")
+	require.NoError(t, err)
+	_, err = time.Parse(time.RFC3339, when)
+	assert.NoError(t, err, when)
+	assert.Equal(t, "2026-01-02", getString(t, s, "day"))
+	assert.Equal(t, "07:32:00", getString(t, s, "clock"))
+
+	assert.Equal(t, 2, getLen(t, s, "servers"))
+	assert.Equal(t, "beta", getString(t, s.Recurse("servers", "1"), "name"))
+	assert.Equal(t, []string{"name", "port"}, mustKeys(t, s, "servers", "1"))
+	assert.Nil(t, s.Recurse("servers", "x"))
+}
+
+func TestTOMLMulti(t *testing.T) {
+	tm, err := UnmarshalFile("common.toml", WithFS(content))
+	require.NoError(t, err, "open common.toml")
+	y, err := UnmarshalFile("common.yaml", WithFS(content))
+	require.NoError(t, err, "open common.yaml")
+	s := NewMultiSource(tm, y)
+	checkList(t, stds, s, "a", "b")
+	checkList(t,
+		[]expectation{
+			{
+				path: []string{"b", "a"},
+				cmd:  "len",
+				want: 4,
+			},
+			{
+				path: []string{"b", "d"},
+				cmd:  "keys",
+				want: []string{"t", "y"},
+			},
+		},
+		s, "a")
+}
+
+func mustKeys(t *testing.T, s Source, args ...string) []string {
+	k, err := s.Keys(args...)
+	require.NoError(t, err, "keys")
+	return k
+}
@@ -0,0 +1,410 @@
+package nflex
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// orderedMap is a map that remembers the order of its keys
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// valueSource is a Source over already-decoded Go values.  Maps
+// are *orderedMap, slices are []interface{}, and scalars are one of
+// nil, bool, int64, uint64, float64, string, or time.Time.  uint64
+// is only used for values that do not fit in an int64.
+type valueSource struct {
+	value      interface{}
+	debugID    int
+	pathToHere []string
+	label      string
+}
+
+// NewValueSource creates a Source from Go values, like the
+// map[string]interface{} and []interface{} that encoding/json
+// produces, so that in-memory values can join a MultiSource:
+//
+//	defaults, err := NewValueSource(map[string]interface{}{
+//		"port":    8080,
+//		"timeout": 30 * time.Second,
+//	})
+//	source := NewMultiSource(file, defaults)
+//
+// Maps must have string keys.  Go maps do not keep the order of
+// their keys so Keys returns them sorted.  Slices and arrays are
+// Slice, all integer types are Int, and both float types are Float.
+// Structs are Map as described in NewStructSource.  time.Time values
+// are String in RFC 3339 format and time.Duration values are String
+// in the format of time.Duration.String so that GetTime and
+// GetDuration can read them back.  Pointers and interfaces are
+// followed.  Other types are an error.  The values are copied so
+// later changes to them do not change the Source.
+func NewValueSource(value interface{}) (Source, error) {
+	v, err := normalizeValue(reflect.ValueOf(value), nil)
+	if err != nil {
+		return nil, err
+	}
+	p := valueSource{
+		value:   v,
+		debugID: debugID(),
+	}
+	debug("nflex/NewValueSource", p.debugID, p.debugKeys)
+	return p, nil
+}
+
+// NewStructSource creates a Source from a struct, or a pointer to a
+// struct, so that compiled-in defaults can be a struct literal:
+//
+//	defaults, err := NewStructSource(Config{Port: 8080})
+//	source := NewMultiSource(file, defaults)
+//
+// Fields are named and skipped with the same `nflex` tags that Decode
+// uses and the fields of embedded structs are fields of the outer
+// struct.  Fields tagged omitempty are left out when they are zero.
+// Keys are in the order of the fields.  Nested structs and
+// maps with string keys are Map, slices and arrays are Slice, and
+// nil pointers, maps, and slices are Nil.  Other values are as
+// described in NewValueSource.
+func NewStructSource(value interface{}) (Source, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, errors.Wrapf(ErrWrongType, "NewStructSource needs a struct, not %T", value)
+	}
+	return NewValueSource(value)
+}
+
+// normalizeValue converts Go values into the values that
+// valueSource uses
+func normalizeValue(v reflect.Value, path []string) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Type() {
+	case timeType:
+		if !v.CanInterface() {
+			return nil, errors.Wrapf(ErrWrongType, "key %v is an unexported %s", path, v.Type())
+		}
+		return v.Interface().(time.Time), nil
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return normalizeValue(v.Elem(), path)
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errors.Wrapf(ErrWrongType, "key %v is a %s: map keys must be strings", path, v.Type())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := &orderedMap{
+			keys:   make([]string, 0, v.Len()),
+			values: make(map[string]interface{}, v.Len()),
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			e, err := normalizeValue(iter.Value(), combine(path, []string{k}))
+			if err != nil {
+				return nil, err
+			}
+			m.keys = append(m.keys, k)
+			m.values[k] = e
+		}
+		sort.Strings(m.keys)
+		return m, nil
+	case reflect.Struct:
+		m := &orderedMap{
+			keys:   make([]string, 0, v.NumField()),
+			values: make(map[string]interface{}, v.NumField()),
+		}
+		if err := normalizeStruct(v, path, m, false); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		a := make([]interface{}, v.Len())
+		for i := range a {
+			var err error
+			a[i], err = normalizeValue(v.Index(i), combine(path, []string{strconv.Itoa(i)}))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	default:
+		return nil, errors.Wrapf(ErrWrongType, "key %v is a %s which cannot be used in a Source", path, v.Type())
+	}
+}
+
+// normalizeStruct adds the fields of a struct to m.  Fields of
+// embedded structs do not replace fields of the outer struct.
+func normalizeStruct(v reflect.Value, path []string, m *orderedMap, embedded bool) error {
+	for _, field := range structFields(v.Type()) {
+		fv := v.Field(field.index)
+		if field.inline {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if err := normalizeStruct(fv, path, m, true); err != nil {
+				return err
+			}
+			continue
+		}
+		_, exists := m.values[field.name]
+		if exists && embedded || field.omitEmpty && fv.IsZero() {
+			continue
+		}
+		e, err := normalizeValue(fv, combine(path, []string{field.name}))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			m.keys = append(m.keys, field.name)
+		}
+		m.values[field.name] = e
+	}
+	return nil
+}
+
+func (p valueSource) lookup(keys []string) (interface{}, bool) {
+	v := p.value
+	for _, key := range keys {
+		switch c := v.(type) {
+		case *orderedMap:
+			var ok bool
+			v, ok = c.values[key]
+			if !ok {
+				return nil, false
+			}
+		case []interface{}:
+			if !isNumberRE.MatchString(key) {
+				return nil, false
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(c) {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (p valueSource) Exists(keys ...string) bool {
+	_, ok := p.lookup(keys)
+	return ok
+}
+
+func (p valueSource) Recurse(keys ...string) Source {
+	if len(keys) == 0 {
+		debug("nflex/value Recurse()", id(p), "-> self")
+		return p
+	}
+	v, ok := p.lookup(keys)
+	if !ok {
+		debug("nflex/value Recurse(", keys, ")", id(p), "-> nil")
+		return nil
+	}
+	n := valueSource{
+		value:      v,
+		pathToHere: combine(p.pathToHere, keys),
+		debugID:    debugID(),
+		label:      p.label,
+	}
+	debug("nflex/value Recurse(", keys, ")", id(p), "->", id(n))
+	return n
+}
+
+func (p valueSource) GetBool(keys ...string) (bool, error) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return false, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, keys))
+	}
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, errors.Wrapf(ErrWrongType, "key %v is a %s (not a boolean)", combine(p.pathToHere, keys), valueType(v))
+}
+
+func (p valueSource) GetInt(keys ...string) (int64, error) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, keys))
+	}
+	switch i := v.(type) {
+	case int64:
+		return i, nil
+	case uint64:
+		return 0, errors.Wrapf(ErrWrongType, "key %v value %d overflows int64", combine(p.pathToHere, keys), i)
+	default:
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not an integer)", combine(p.pathToHere, keys), valueType(v))
+	}
+}
+
+func (p valueSource) GetUInt(keys ...string) (uint64, error) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, keys))
+	}
+	switch i := v.(type) {
+	case int64:
+		if i < 0 {
+			return 0, errors.Wrapf(ErrWrongType, "key %v is negative (%d)", combine(p.pathToHere, keys), i)
+		}
+		return uint64(i), nil
+	case uint64:
+		return i, nil
+	default:
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not an integer)", combine(p.pathToHere, keys), valueType(v))
+	}
+}
+
+func (p valueSource) GetFloat(keys ...string) (float64, error) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, keys))
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	default:
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not a number)", combine(p.pathToHere, keys), valueType(v))
+	}
+}
+
+func (p valueSource) GetString(keys ...string) (string, error) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return "", errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, keys))
+	}
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case time.Time:
+		return formatTime(s), nil
+	default:
+		return "", errors.Wrapf(ErrWrongType, "key %v is a %s (not a string)", combine(p.pathToHere, keys), valueType(v))
+	}
+}
+
+func (p valueSource) Keys(keys ...string) ([]string, error) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return nil, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, keys))
+	}
+	switch m := v.(type) {
+	case nil:
+		return nil, nil
+	case *orderedMap:
+		ret := make([]string, len(m.keys))
+		copy(ret, m.keys)
+		return ret, nil
+	default:
+		return nil, errors.Wrapf(ErrWrongType, "key %v is a %s (not a map)", combine(p.pathToHere, keys), valueType(v))
+	}
+}
+
+func (p valueSource) Len(keys ...string) (int, error) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return 0, errors.Wrapf(ErrDoesNotExist, "key %v does not exist", combine(p.pathToHere, keys))
+	}
+	switch a := v.(type) {
+	case nil:
+		return 0, nil
+	case []interface{}:
+		return len(a), nil
+	default:
+		return 0, errors.Wrapf(ErrWrongType, "key %v is a %s (not an array)", combine(p.pathToHere, keys), valueType(v))
+	}
+}
+
+func (p valueSource) Type(keys ...string) NodeType {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return Undefined
+	}
+	return valueType(v)
+}
+
+func valueType(v interface{}) NodeType {
+	switch v.(type) {
+	case nil:
+		return Nil
+	case bool:
+		return Bool
+	case int64, uint64:
+		return Int
+	case float64:
+		return Float
+	case string, time.Time:
+		return String
+	case *orderedMap:
+		return Map
+	case []interface{}:
+		return Slice
+	default:
+		return Undefined
+	}
+}
+
+func (p valueSource) Label() string  { return p.label }
+func (p valueSource) path() []string { return p.pathToHere }
+
+func (p valueSource) getTime(keys ...string) (time.Time, bool) {
+	v, ok := p.lookup(keys)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, ok := v.(time.Time)
+	return t, ok
+}
+
+func (p valueSource) withLabel(label string) Source {
+	p.label = label
+	return p
+}
+
+func (p valueSource) debugKeys() string {
+	return debugKeys(p)
+}
@@ -0,0 +1,175 @@
+package nflex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToInterface(t *testing.T) {
+	s, err := UnmarshalYAML([]byte(`
+db:
+  host: localhost
+  port: 5432
+  ratio: 0.5
+  tls: false
+  replicas: [a, b]
+  options: ~
+  id: 18446744073709551615
+`))
+	require.NoError(t, err)
+	got, err := ToInterface(s, "db")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"host":     "localhost",
+		"port":     int64(5432),
+		"ratio":    0.5,
+		"tls":      false,
+		"replicas": []interface{}{"a", "b"},
+		"options":  nil,
+		"id":       uint64(18446744073709551615),
+	}, got)
+
+	port, err := ToInterface(s, "db", "port")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5432), port)
+
+	_, err = ToInterface(s, "db", "missing")
+	assert.ErrorIs(t, err, ErrDoesNotExist)
+}
+
+func TestNewValueSource(t *testing.T) {
+	type port uint16
+	in := map[string]interface{}{
+		"port":    port(8080),
+		"timeout": 30 * time.Second,
+		"ratio":   float32(0.5),
+		"hosts":   []string{"a", "b"},
+		"limits":  map[string]int{"cpu": 2, "mem": 4},
+		"fixed":   [2]bool{true, false},
+		"none":    (*int)(nil),
+		"big":     uint64(18446744073709551615),
+		"when":    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	defaults, err := NewValueSource(in)
+	require.NoError(t, err)
+	in["port"] = 1
+
+	assert.Equal(t, []string{"big", "fixed", "hosts", "limits", "none", "port", "ratio", "timeout", "when"}, mustKeys(t, defaults))
+	i, err := defaults.GetInt("port")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8080), i, "copied")
+	d, err := GetDuration(defaults, "timeout")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, d)
+	when, err := GetTime(defaults, "when")
+	require.NoError(t, err)
+	assert.True(t, in["when"].(time.Time).Equal(when))
+	assert.Equal(t, Nil, defaults.Type("none"))
+	assert.Equal(t, Int, defaults.Type("big"))
+	_, err = defaults.GetInt("big")
+	assert.ErrorIs(t, err, ErrWrongType)
+	u, err := GetUInt(defaults, "big")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(18446744073709551615), u)
+
+	file, err := UnmarshalJSON([]byte(`{"port":9090,"hosts":["c"]}`))
+	require.NoError(t, err)
+	m := MultiSourceSetCombine(false).Apply(NewMultiSource(file, defaults))
+	i, err = m.GetInt("port")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9090), i)
+	i, err = m.GetInt("limits", "mem")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), i)
+	assert.Equal(t, 1, getLen(t, m, "hosts"))
+
+	got, err := MarshalJSON(defaults)
+	require.NoError(t, err)
+	assert.Equal(t, `{"big":18446744073709551615,"fixed":[true,false],"hosts":["a","b"],"limits":{"cpu":2,"mem":4},`+
+		`"none":null,"port":8080,"ratio":0.5,"timeout":"30s","when":"2026-01-01T00:00:00Z"}`, string(got))
+
+	back, err := ToInterface(defaults)
+	require.NoError(t, err)
+	again, err := NewValueSource(back)
+	require.NoError(t, err)
+	round, err := MarshalJSON(again)
+	require.NoError(t, err)
+	assert.Equal(t, string(got), string(round))
+
+	_, err = NewValueSource(map[int]string{1: "x"})
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = NewValueSource(map[string]interface{}{"f": func() {}})
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.Contains(t, err.Error(), "[f]")
+}
+
+type testDefaults struct {
+	testEmbedded
+	Port     uint16        `nflex:"port"`
+	Timeout  time.Duration `nflex:"timeout"`
+	Secret   string        `nflex:"-"`
+	TLS      *testTLS      `nflex:"tls"`
+	Backup   *testTLS      `nflex:"backup"`
+	Hosts    []string      `nflex:"hosts"`
+	Labels   map[string]string
+	Ratio    float64
+	internal int
+}
+
+type testTLS struct {
+	Cert    string `nflex:"cert"`
+	Enabled bool   `nflex:"enabled"`
+}
+
+func TestNewStructSource(t *testing.T) {
+	in := testDefaults{
+		testEmbedded: testEmbedded{Name: "svc"},
+		Port:         8080,
+		Timeout:      30 * time.Second,
+		Secret:       "hidden",
+		TLS:          &testTLS{Cert: "/etc/cert.pem"},
+		Hosts:        []string{"a"},
+		Labels:       map[string]string{"tier": "web"},
+		internal:     3,
+	}
+	defaults, err := NewStructSource(&in)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "port", "timeout", "tls", "backup", "hosts", "labels", "ratio"}, mustKeys(t, defaults))
+	assert.Equal(t, Map, defaults.Type("tls"))
+	assert.Equal(t, Nil, defaults.Type("backup"))
+	assert.Equal(t, Bool, defaults.Type("tls", "enabled"))
+	assert.Equal(t, Slice, defaults.Type("hosts"))
+	assert.Equal(t, Float, defaults.Type("ratio"))
+	assert.False(t, defaults.Exists("secret"))
+	assert.Equal(t, "svc", getString(t, defaults, "name"))
+
+	var back testDefaults
+	require.NoError(t, Decode(defaults, &back))
+	in.Secret = ""
+	in.internal = 0
+	assert.Equal(t, in, back)
+
+	file, err := UnmarshalYAML([]byte("port: 9090\ntls:\n  enabled: true\n"))
+	require.NoError(t, err)
+	var got testDefaults
+	require.NoError(t, Decode(NewMultiSource(file, defaults), &got))
+	assert.Equal(t, uint16(9090), got.Port)
+	assert.Equal(t, 30*time.Second, got.Timeout)
+	assert.Equal(t, &testTLS{Cert: "/etc/cert.pem", Enabled: true}, got.TLS)
+
+	_, err = NewStructSource(map[string]int{})
+	assert.ErrorIs(t, err, ErrWrongType)
+}
+
+func TestNewStructSourceOmitEmpty(t *testing.T) {
+	defaults, err := NewStructSource(struct {
+		Name  string `nflex:"name,omitempty"`
+		Port  int    `nflex:"port,omitempty"`
+		Debug bool   `nflex:"debug"`
+	}{Port: 80})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"port", "debug"}, mustKeys(t, defaults))
+}
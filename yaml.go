@@ -1,21 +1,23 @@
 package nflex
 
 import (
+	"bytes"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 )
 
 type parsedYAML struct {
-	root *yaml.Node
-	// nodes store maps as alternating key/value in an array
-	// cache turns that into a map
-	cache map[*yaml.Node]map[string]*yaml.Node
+	root  *yaml.Node
+	cache *yamlIndex
 
 	debugID    int
 	pathToHere []string
+	label      string
 }
 
 func UnmarshalYAML(data []byte) (Source, error) {
@@ -26,7 +28,7 @@ func UnmarshalYAML(data []byte) (Source, error) {
 	}
 	p := parsedYAML{
 		root:    &node,
-		cache:   make(map[*yaml.Node]map[string]*yaml.Node),
+		cache:   newYAMLIndex(&node, data),
 		debugID: debugID(),
 	}
 	debug("nflex/UnmarshalYAML", p.debugID, p.debugKeys)
@@ -56,6 +58,7 @@ func (p parsedYAML) Recurse(keys ...string) Source {
 		cache:      p.cache,
 		pathToHere: combine(p.pathToHere, keys),
 		debugID:    debugID(),
+		label:      p.label,
 	}
 	debug("nflex/yaml Recurse(", keys, ")", id(p), "->", id(np), np.debugKeys)
 	return np
@@ -66,9 +69,17 @@ func (p parsedYAML) GetBool(keys ...string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	b, err := strconv.ParseBool(n.Value)
+	if err := p.checkTag(n, keys, "a bool", Bool); err != nil {
+		return false, err
+	}
+	var b bool
+	if yamlScalarType(n) == Bool {
+		b, err = parseYAMLBool(n)
+	} else {
+		b, err = strconv.ParseBool(n.Value)
+	}
 	if err != nil {
-		return false, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(p.pathToHere, keys), err)
+		return false, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s%s", combine(p.pathToHere, keys), err, p.at(keys))
 	}
 	return b, nil
 }
@@ -78,9 +89,12 @@ func (p parsedYAML) GetInt(keys ...string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	i, err := strconv.ParseInt(n.Value, 10, 64)
+	if err := p.checkTag(n, keys, "an integer", Int); err != nil {
+		return 0, err
+	}
+	i, err := parseInt(n.Value)
 	if err != nil {
-		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(p.pathToHere, keys), err)
+		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s%s", combine(p.pathToHere, keys), err, p.at(keys))
 	}
 	return i, nil
 }
@@ -90,9 +104,12 @@ func (p parsedYAML) GetUInt(keys ...string) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
-	i, err := strconv.ParseUint(n.Value, 10, 64)
+	if err := p.checkTag(n, keys, "an integer", Int); err != nil {
+		return 0, err
+	}
+	i, err := parseUInt(n.Value)
 	if err != nil {
-		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(p.pathToHere, keys), err)
+		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s%s", combine(p.pathToHere, keys), err, p.at(keys))
 	}
 	return i, nil
 }
@@ -102,9 +119,12 @@ func (p parsedYAML) GetFloat(keys ...string) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	f, err := strconv.ParseFloat(n.Value, 64)
+	if err := p.checkTag(n, keys, "a number", Int, Float); err != nil {
+		return 0, err
+	}
+	f, err := parseFloat(n.Value)
 	if err != nil {
-		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s", combine(p.pathToHere, keys), err)
+		return 0, errors.Wrapf(ErrWrongType, "Lookup %v, parse error: %s%s", combine(p.pathToHere, keys), err, p.at(keys))
 	}
 	return f, nil
 }
@@ -117,34 +137,94 @@ func (p parsedYAML) GetString(keys ...string) (string, error) {
 	return n.Value, nil
 }
 
-var boolRE = regexp.MustCompile(`^(?:true|True|TRUE|false|False|FALSE|t|T|f|F)$`)
-var intRE = regexp.MustCompile(`^\d+$`)
-var floatRE = regexp.MustCompile(`^(?:(?:\.\d+(?:[eE][-+]?\d+)?)|(?:\d+(?:\.\d+(?:[eE][-+]?\d+)?)?))$`)
+var boolRE = regexp.MustCompile(`^(?:true|True|TRUE|false|False|FALSE)$`)
+var nullRE = regexp.MustCompile(`^(?:~|null|Null|NULL|)$`)
+var yaml11BoolRE = regexp.MustCompile(`^(?i:y|yes|n|no|on|off)$`)
+
+// scalarType guesses the type of an untyped scalar
+func scalarType(s string) NodeType {
+	if boolRE.MatchString(s) {
+		return Bool
+	}
+	return numberType(s)
+}
+
+// yamlTagTypes are the types of the standard YAML tags
+var yamlTagTypes = map[string]NodeType{
+	"!!str":       String,
+	"!!binary":    String,
+	"!!timestamp": String,
+	"!!int":       Int,
+	"!!float":     Float,
+	"!!bool":      Bool,
+	"!!null":      Nil,
+}
+
+// yamlScalarType types a YAML scalar.  An explicit tag decides the
+// type.  Without one, quoted and block scalars are strings and plain
+// scalars are typed by their value.  Custom tags, like "!secret",
+// do not change the type.  The type is what Type reports; the
+// getters convert the text of untagged scalars, as they do for JSON
+// strings, so GetInt reads "8080" even though it is a String.
+func yamlScalarType(n *yaml.Node) NodeType {
+	if n.Style&yaml.TaggedStyle != 0 {
+		if t, ok := yamlTagTypes[n.Tag]; ok {
+			return t
+		}
+	}
+	if n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return String
+	}
+	if nullRE.MatchString(n.Value) {
+		return Nil
+	}
+	return scalarType(n.Value)
+}
+
+// checkTag rejects scalars that have an explicit standard tag, like
+// "!!str", for a type that is not one of types
+func (p parsedYAML) checkTag(n *yaml.Node, keys []string, what string, types ...NodeType) error {
+	if n.Style&yaml.TaggedStyle == 0 {
+		return nil
+	}
+	t, ok := yamlTagTypes[n.Tag]
+	if !ok {
+		return nil
+	}
+	for _, want := range types {
+		if t == want {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrWrongType, "Lookup %v is tagged %s (not %s)%s", combine(p.pathToHere, keys), n.Tag, what, p.at(keys))
+}
+
+// parseYAMLBool also accepts the YAML 1.1 forms, like "yes", when
+// they are tagged !!bool
+func parseYAMLBool(n *yaml.Node) (bool, error) {
+	if yaml11BoolRE.MatchString(n.Value) {
+		switch strings.ToLower(n.Value) {
+		case "y", "yes", "on":
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+	return strconv.ParseBool(n.Value)
+}
 
 func (p parsedYAML) Type(keys ...string) NodeType {
 	n, err := p.lookup(p.root, keys)
-	if err != nil {
+	if err != nil || n == nil {
 		return Undefined
 	}
-	if n == nil {
-		return Nil
-	}
 	switch n.root.Kind {
 	case yaml.DocumentNode, yaml.MappingNode:
 		return Map
 	case yaml.SequenceNode:
 		return Slice
 	case yaml.ScalarNode:
-		if boolRE.MatchString(n.root.Value) {
-			return Bool
-		}
-		if intRE.MatchString(n.root.Value) {
-			return Int
-		}
-		if floatRE.MatchString(n.root.Value) {
-			return Float
-		}
-		return String
+		return yamlScalarType(n.root)
 	default:
 		return Undefined // this shouldn't happen
 	}
@@ -153,13 +233,16 @@ func (p parsedYAML) Type(keys ...string) NodeType {
 func (p parsedYAML) Len(keys ...string) (int, error) {
 	n, err := p.lookup(p.root, keys)
 	if err != nil {
-		return 0, errors.Wrapf(ErrDoesNotExist, "Could not get %v: %s", combine(p.pathToHere, keys), err)
+		return 0, errors.Wrapf(ErrDoesNotExist, "Could not get %v: %s%s", combine(p.pathToHere, keys), err, p.near(keys))
 	}
 	if n == nil {
-		return 0, errors.Wrapf(ErrDoesNotExist, "Could not get %v", combine(p.pathToHere, keys))
+		return 0, errors.Wrapf(ErrDoesNotExist, "Could not get %v%s", combine(p.pathToHere, keys), p.near(keys))
+	}
+	if n.root.Kind == yaml.ScalarNode && yamlScalarType(n.root) == Nil {
+		return 0, nil
 	}
 	if n.root.Kind != yaml.SequenceNode {
-		return 0, errors.Wrapf(ErrWrongType, "Len %s is a %d", combine(p.pathToHere, keys), n.root.Kind)
+		return 0, errors.Wrapf(ErrWrongType, "Len %s is a %d%s", combine(p.pathToHere, keys), n.root.Kind, p.at(keys))
 	}
 	return len(n.root.Content), nil
 }
@@ -167,10 +250,10 @@ func (p parsedYAML) Len(keys ...string) (int, error) {
 func (p parsedYAML) Keys(keys ...string) ([]string, error) {
 	n, err := p.lookup(p.root, keys)
 	if err != nil {
-		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v: %s", combine(p.pathToHere, keys), err)
+		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v: %s%s", combine(p.pathToHere, keys), err, p.near(keys))
 	}
 	if n == nil {
-		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v", combine(p.pathToHere, keys))
+		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v%s", combine(p.pathToHere, keys), p.near(keys))
 	}
 	root := n.root
 	if root.Kind == yaml.DocumentNode {
@@ -180,8 +263,11 @@ func (p parsedYAML) Keys(keys ...string) ([]string, error) {
 		}
 		root = root.Content[0]
 	}
+	if root.Kind == yaml.ScalarNode && yamlScalarType(root) == Nil {
+		return nil, nil
+	}
 	if root.Kind != yaml.MappingNode {
-		return nil, errors.Wrapf(ErrWrongType, "Keys %s is a %d", combine(p.pathToHere, keys), n.root.Kind)
+		return nil, errors.Wrapf(ErrWrongType, "Keys %s is a %d%s", combine(p.pathToHere, keys), n.root.Kind, p.at(keys))
 	}
 	ret := make([]string, len(root.Content)/2)
 	for i := 0; i < len(root.Content); i += 2 {
@@ -193,13 +279,13 @@ func (p parsedYAML) Keys(keys ...string) ([]string, error) {
 func (p parsedYAML) lookupScalar(keys []string) (*yaml.Node, error) {
 	n, err := p.lookup(p.root, keys)
 	if err != nil {
-		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v: %s", combine(p.pathToHere, keys), err)
+		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v: %s%s", combine(p.pathToHere, keys), err, p.near(keys))
 	}
 	if n == nil {
-		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v", combine(p.pathToHere, keys))
+		return nil, errors.Wrapf(ErrDoesNotExist, "Could not get %v%s", combine(p.pathToHere, keys), p.near(keys))
 	}
 	if n.root.Kind != yaml.ScalarNode {
-		return nil, errors.Wrapf(ErrWrongType, "Lookup %v is a %d", combine(p.pathToHere, keys), n.root.Kind)
+		return nil, errors.Wrapf(ErrWrongType, "Lookup %v is a %d%s", combine(p.pathToHere, keys), n.root.Kind, p.at(keys))
 	}
 	return n.root, nil
 }
@@ -234,17 +320,11 @@ func (p parsedYAML) lookup(n *yaml.Node, keys []string) (*parsedYAML, error) {
 			}
 			n = n.Content[i]
 		case yaml.MappingNode:
-			if _, ok := p.cache[n]; !ok {
-				cache := make(map[string]*yaml.Node)
-				p.cache[n] = cache
-				if len(n.Content)%2 != 0 {
-					return nil, errors.Errorf("mapping node %s/%s has non-even content", n.Tag, n.Anchor)
-				}
-				for i := 0; i < len(n.Content); i += 2 {
-					cache[n.Content[i].Value] = n.Content[i+1]
-				}
+			m, err := p.cache.get(n)
+			if err != nil {
+				return nil, err
 			}
-			n = p.cache[n][key]
+			n = m[key]
 		case yaml.ScalarNode:
 			return nil, errors.Errorf("Cannot index through scalar with '%s'", combine(p.pathToHere, original))
 		case yaml.AliasNode:
@@ -253,6 +333,12 @@ func (p parsedYAML) lookup(n *yaml.Node, keys []string) (*parsedYAML, error) {
 		}
 		keys = keys[1:]
 	}
+	if n != nil && n.Kind == yaml.DocumentNode && len(n.Content) != 0 {
+		n = n.Content[0]
+	}
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
 	if n == nil {
 		return nil, nil
 	}
@@ -263,6 +349,160 @@ func (p parsedYAML) lookup(n *yaml.Node, keys []string) (*parsedYAML, error) {
 	}, nil
 }
 
+func (p parsedYAML) Tag(keys ...string) string {
+	n, err := p.lookup(p.root, keys)
+	if err != nil || n == nil {
+		return ""
+	}
+	node := n.root
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+	if node.Style&yaml.TaggedStyle == 0 {
+		return ""
+	}
+	return node.Tag
+}
+
+func (p parsedYAML) Position(keys ...string) (string, int, int, bool) {
+	n, err := p.lookup(p.root, keys)
+	if err != nil || n == nil {
+		return "", 0, 0, false
+	}
+	node := n.root
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+	if node.Line == 0 {
+		// added by Set
+		return "", 0, 0, false
+	}
+	return p.label, node.Line, node.Column, true
+}
+
+func (p parsedYAML) keyPosition(keys ...string) (string, int, int, bool) {
+	if len(keys) == 0 {
+		return "", 0, 0, false
+	}
+	n, err := p.lookup(p.root, keys[:len(keys)-1])
+	if err != nil || n == nil {
+		return "", 0, 0, false
+	}
+	node := n.root
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return "", 0, 0, false
+	}
+	key := keys[len(keys)-1]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k := node.Content[i]
+		if k.Kind == yaml.ScalarNode && k.Value == key && k.Line != 0 {
+			return p.label, k.Line, k.Column, true
+		}
+	}
+	// from a merge key, or added by Set
+	return "", 0, 0, false
+}
+
+func (p parsedYAML) at(keys []string) string {
+	return positionSuffix(p.Position(keys...))
+}
+
+func (p parsedYAML) near(keys []string) string {
+	return nearSuffix(p, keys)
+}
+
+func (p parsedYAML) Label() string  { return p.label }
+func (p parsedYAML) path() []string { return p.pathToHere }
+
+func (p parsedYAML) withLabel(label string) Source {
+	p.label = label
+	return p
+}
+
+// yamlIndex is shared by every parsedYAML derived from one document.
+// Nodes store maps as alternating key/value in an array; the index
+// turns that into a map.  It is filled lazily and may be used by
+// many goroutines at once.  The index also keeps the text of the
+// document so that it can be written back after edits.
+type yamlIndex struct {
+	mu   sync.RWMutex
+	maps map[*yaml.Node]map[string]*yaml.Node
+
+	doc      *yaml.Node
+	text     []byte
+	original map[*yaml.Node]*yaml.Node // see snapshot
+}
+
+func newYAMLIndex(doc *yaml.Node, text []byte) *yamlIndex {
+	return &yamlIndex{
+		maps: make(map[*yaml.Node]map[string]*yaml.Node),
+		doc:  doc,
+		text: append([]byte(nil), firstYAMLDocument(text)...),
+	}
+}
+
+// firstYAMLDocument is the text of the first document in a YAML
+// stream, which is the only one that UnmarshalYAML reads.  Document
+// markers are always at the start of a line and cannot appear inside
+// the content of a document.  Comments and directives before the
+// first document are part of it.
+func firstYAMLDocument(text []byte) []byte {
+	content := false
+	for at := 0; at < len(text); {
+		end := bytes.IndexByte(text[at:], '\n') + 1
+		if end == 0 {
+			end = len(text) - at
+		}
+		line := text[at : at+end]
+		marker := func(m string) bool {
+			return bytes.HasPrefix(line, []byte(m)) &&
+				(len(line) == len(m) || line[len(m)] == ' ' || line[len(m)] == '\t' || line[len(m)] == '\n' || line[len(m)] == '\r')
+		}
+		switch {
+		case marker("..."):
+			if content {
+				return text[:at]
+			}
+		case marker("---"):
+			if content {
+				return text[:at]
+			}
+			// the first document starts here, even if it is empty
+			content = true
+		default:
+			trimmed := bytes.TrimSpace(line)
+			if len(trimmed) != 0 && trimmed[0] != '#' && !(trimmed[0] == '%' && !content) {
+				content = true
+			}
+		}
+		at += end
+	}
+	return text
+}
+
+func (x *yamlIndex) get(n *yaml.Node) (map[string]*yaml.Node, error) {
+	x.mu.RLock()
+	m, ok := x.maps[n]
+	x.mu.RUnlock()
+	if ok {
+		return m, nil
+	}
+	if len(n.Content)%2 != 0 {
+		return nil, errors.Errorf("mapping node %s/%s has non-even content", n.Tag, n.Anchor)
+	}
+	m = make(map[string]*yaml.Node, len(n.Content)/2)
+	for i := 0; i < len(n.Content); i += 2 {
+		m[n.Content[i].Value] = n.Content[i+1]
+	}
+	x.mu.Lock()
+	x.maps[n] = m
+	x.mu.Unlock()
+	return m, nil
+}
+
 func (p parsedYAML) debugKeys() string {
 	return debugKeys(p)
 }
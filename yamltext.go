@@ -0,0 +1,592 @@
+package nflex
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// snapshot copies the document before its first edit.  When the
+// document is written back, the edits are found by comparing the
+// nodes with their copies and only the text of edited nodes is
+// replaced.
+func (x *yamlIndex) snapshot() {
+	if x.original != nil {
+		return
+	}
+	x.original = make(map[*yaml.Node]*yaml.Node)
+	copyYAML(x.doc, x.original)
+}
+
+func copyYAML(n *yaml.Node, copies map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if c, ok := copies[n]; ok {
+		return c
+	}
+	c := *n
+	copies[n] = &c
+	if n.Alias != nil {
+		c.Alias = copyYAML(n.Alias, copies)
+	}
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, e := range n.Content {
+		c.Content[i] = copyYAML(e, copies)
+	}
+	return &c
+}
+
+// marshal writes the document back out.  The parsed text is kept for
+// everything that was not edited.  If the edits cannot be applied to
+// the text, the whole document is encoded instead: comments, order,
+// anchors, and quoting are still kept but the layout may change.
+func (x *yamlIndex) marshal() ([]byte, error) {
+	if x.original == nil {
+		return append([]byte(nil), x.text...), nil
+	}
+	d := newYAMLDiff(x.text, x.original)
+	d.indent = yamlIndent(x.original[x.doc])
+	if len(x.doc.Content) == 1 && d.node(x.doc.Content[0]) {
+		text := d.apply()
+		var check yaml.Node
+		if yaml.Unmarshal(text, &check) == nil && equivalentYAML(&check, x.doc) {
+			return text, nil
+		}
+	}
+	if len(x.doc.Content) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(d.indent)
+	err := enc.Encode(x.doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "write yaml")
+	}
+	return buf.Bytes(), errors.Wrap(enc.Close(), "write yaml")
+}
+
+// yamlEdit replaces text[start:end]
+type yamlEdit struct {
+	start       int
+	end         int
+	replacement string
+}
+
+// yamlExtent is the lines that hold one entry of a block map or
+// sequence
+type yamlExtent struct {
+	lead  int // first line, including comments just above the entry
+	start int // line of the key or dash
+	end   int // after the last line of the value
+	next  int // lead of the following entry
+}
+
+// yamlDiff finds the text edits that turn the original document into
+// the edited one
+type yamlDiff struct {
+	text     []byte
+	lines    []int // offset of the start of each line
+	original map[*yaml.Node]*yaml.Node
+	indent   int
+	edits    []yamlEdit
+}
+
+func newYAMLDiff(text []byte, original map[*yaml.Node]*yaml.Node) *yamlDiff {
+	d := &yamlDiff{
+		text:     text,
+		lines:    []int{0},
+		original: original,
+	}
+	for i, c := range text {
+		if c == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d
+}
+
+func (d *yamlDiff) apply() []byte {
+	sort.SliceStable(d.edits, func(i, j int) bool {
+		if d.edits[i].start != d.edits[j].start {
+			return d.edits[i].start < d.edits[j].start
+		}
+		return d.edits[i].end < d.edits[j].end
+	})
+	var buf bytes.Buffer
+	at := 0
+	for _, e := range d.edits {
+		buf.Write(d.text[at:e.start])
+		buf.WriteString(e.replacement)
+		at = e.end
+	}
+	buf.Write(d.text[at:])
+	return buf.Bytes()
+}
+
+// node adds the edits for one node.  It returns false if the node
+// cannot be edited in place and must be rewritten by its parent.
+func (d *yamlDiff) node(c *yaml.Node) bool {
+	o := d.original[c]
+	if o == nil {
+		return false
+	}
+	if sameYAML(c, o) {
+		return true
+	}
+	mark := len(d.edits)
+	var ok bool
+	switch {
+	case c.Kind == yaml.ScalarNode && o.Kind == yaml.ScalarNode:
+		ok = d.scalar(c, o)
+	case c.Kind != o.Kind, c.Tag != o.Tag, c.Anchor != o.Anchor, (c.Style|o.Style)&yaml.FlowStyle != 0:
+		ok = false
+	case c.Kind == yaml.MappingNode:
+		ok = d.mapping(c, o)
+	case c.Kind == yaml.SequenceNode:
+		ok = d.sequence(c, o)
+	}
+	if !ok {
+		d.edits = d.edits[:mark]
+	}
+	return ok
+}
+
+// scalar replaces a scalar that fits on one line
+func (d *yamlDiff) scalar(c *yaml.Node, o *yaml.Node) bool {
+	if o.Value == "" || o.Anchor != "" || c.Anchor != "" ||
+		o.Style&(yaml.TaggedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return false
+	}
+	start, ok := d.offset(o.Line, o.Column)
+	if !ok {
+		return false
+	}
+	end, ok := d.scalarEnd(o, start)
+	if !ok {
+		return false
+	}
+	r := *c
+	r.HeadComment, r.LineComment, r.FootComment = "", "", ""
+	out, err := yaml.Marshal(&r)
+	if err != nil {
+		return false
+	}
+	replacement := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(replacement, "\n") {
+		return false
+	}
+	d.edits = append(d.edits, yamlEdit{start: start, end: end, replacement: replacement})
+	return true
+}
+
+func (d *yamlDiff) scalarEnd(o *yaml.Node, start int) (int, bool) {
+	switch o.Style {
+	case 0:
+		end := start + len(o.Value)
+		if end > len(d.text) || string(d.text[start:end]) != o.Value {
+			return 0, false
+		}
+		return end, true
+	case yaml.DoubleQuotedStyle:
+		if d.text[start] != '"' {
+			return 0, false
+		}
+		for i := start + 1; i < len(d.text); i++ {
+			switch d.text[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, true
+			case '\n':
+				return 0, false
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		if d.text[start] != '\'' {
+			return 0, false
+		}
+		for i := start + 1; i < len(d.text); i++ {
+			switch d.text[i] {
+			case '\'':
+				if i+1 < len(d.text) && d.text[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, true
+			case '\n':
+				return 0, false
+			}
+		}
+	}
+	return 0, false
+}
+
+// mapping edits a block map: entries that were removed are deleted,
+// changed entries are edited or rewritten, and new entries are added
+// after the last one
+func (d *yamlDiff) mapping(c *yaml.Node, o *yaml.Node) bool {
+	if len(o.Content) == 0 {
+		return false
+	}
+	// the map itself may start at an anchor or tag
+	indent := o.Content[0].Column - 1
+	entries := make(map[string]int, len(o.Content)/2)
+	starts := make([]int, len(o.Content)/2)
+	inline := make([]bool, len(starts))
+	for i := range starts {
+		k := o.Content[2*i]
+		if _, dup := entries[k.Value]; dup || k.Kind != yaml.ScalarNode {
+			return false
+		}
+		entries[k.Value] = i
+		line, clean, ok := d.entryLine(k.Line, k.Column, indent)
+		if !ok {
+			return false
+		}
+		starts[i], inline[i] = line, !clean
+	}
+	extents := d.extents(starts, indent)
+	keep := make([]bool, len(starts))
+	var added []*yaml.Node
+	for i := 0; i+1 < len(c.Content); i += 2 {
+		k, v := c.Content[i], c.Content[i+1]
+		j, ok := entries[k.Value]
+		if !ok {
+			added = append(added, k, v)
+			continue
+		}
+		keep[j] = true
+		if d.original[v] == o.Content[2*j+1] && d.node(v) {
+			continue
+		}
+		e := extents[j]
+		if inline[j] || !d.render(d.at(e.start), d.at(e.end), indent, yaml.MappingNode, k, v) {
+			return false
+		}
+	}
+	for j, e := range extents {
+		if !keep[j] {
+			if inline[j] {
+				return false
+			}
+			d.edits = append(d.edits, yamlEdit{start: d.at(e.lead), end: d.at(e.next)})
+		}
+	}
+	if len(added) != 0 {
+		at := d.at(extents[len(extents)-1].end)
+		return d.render(at, at, indent, yaml.MappingNode, added...)
+	}
+	return true
+}
+
+// sequence edits a block sequence.  Elements are matched with the
+// originals so that deleting an element does not rewrite the ones
+// that follow it.
+func (d *yamlDiff) sequence(c *yaml.Node, o *yaml.Node) bool {
+	if len(o.Content) == 0 {
+		return false
+	}
+	indent := o.Column - 1
+	if o.Anchor != "" || o.Style&yaml.TaggedStyle != 0 {
+		// the sequence starts at the anchor or tag, not the dash
+		indent = d.indentOf(o.Content[0].Line - 1)
+	}
+	index := make(map[*yaml.Node]int, len(o.Content))
+	starts := make([]int, len(o.Content))
+	inline := make([]bool, len(starts))
+	for i, e := range o.Content {
+		index[e] = i
+		line, clean, ok := d.entryLine(e.Line, indent+1, indent)
+		if !ok || d.text[d.at(line)+indent] != '-' {
+			return false
+		}
+		starts[i], inline[i] = line, !clean
+	}
+	extents := d.extents(starts, indent)
+	keep := make([]bool, len(starts))
+	prev := -1
+	var added []*yaml.Node
+	flush := func() bool {
+		if len(added) == 0 {
+			return true
+		}
+		at := d.at(extents[0].lead)
+		if prev >= 0 {
+			at = d.at(extents[prev].end)
+		}
+		ok := d.render(at, at, indent, yaml.SequenceNode, added...)
+		added = nil
+		return ok
+	}
+	for _, e := range c.Content {
+		j, ok := index[d.original[e]]
+		if !ok {
+			added = append(added, e)
+			continue
+		}
+		if j <= prev {
+			return false
+		}
+		if !flush() {
+			return false
+		}
+		keep[j] = true
+		prev = j
+		if d.node(e) {
+			continue
+		}
+		x := extents[j]
+		if inline[j] || !d.render(d.at(x.start), d.at(x.end), indent, yaml.SequenceNode, e) {
+			return false
+		}
+	}
+	if !flush() {
+		return false
+	}
+	for j, e := range extents {
+		if !keep[j] {
+			if inline[j] {
+				return false
+			}
+			d.edits = append(d.edits, yamlEdit{start: d.at(e.lead), end: d.at(e.next)})
+		}
+	}
+	return true
+}
+
+// render encodes a map or sequence made from content and indents it
+// to replace text[start:end]
+func (d *yamlDiff) render(start, end, indent int, kind yaml.Kind, content ...*yaml.Node) bool {
+	n := &yaml.Node{
+		Kind:    kind,
+		Tag:     "!!map",
+		Content: make([]*yaml.Node, len(content)),
+	}
+	for i, c := range content {
+		n.Content[i] = bareYAML(c)
+	}
+	if kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if (v.Kind == yaml.MappingNode || v.Kind == yaml.SequenceNode) && k.LineComment == "" {
+				// a comment after "key:" is written after the
+				// first entry of the value otherwise
+				k.LineComment, v.LineComment = v.LineComment, ""
+			}
+		}
+	}
+	if kind == yaml.SequenceNode {
+		n.Tag = "!!seq"
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(d.indent)
+	if enc.Encode(n) != nil || enc.Close() != nil {
+		return false
+	}
+	var out strings.Builder
+	if start == end && start > 0 && d.text[start-1] != '\n' {
+		out.WriteByte('\n')
+	}
+	pad := strings.Repeat(" ", indent)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line != "" && line != "\n" {
+			out.WriteString(pad)
+		}
+		out.WriteString(line)
+	}
+	d.edits = append(d.edits, yamlEdit{start: start, end: end, replacement: out.String()})
+	return true
+}
+
+// extents finds the lines of each entry of a block map or sequence.
+// An entry ends at its last line that is indented more than its key
+// or dash.  Comment lines just above an entry belong to it, except
+// for the first entry of the document where they describe the file.
+func (d *yamlDiff) extents(starts []int, indent int) []yamlExtent {
+	extents := make([]yamlExtent, len(starts))
+	for i, s := range starts {
+		lead, above := s, -1
+		if i > 0 {
+			above = starts[i-1]
+		}
+		if i > 0 || indent > 0 {
+			for lead-1 > above && d.isComment(lead-1) && d.indentOf(lead-1) == indent {
+				lead--
+			}
+		}
+		extents[i] = yamlExtent{lead: lead, start: s}
+	}
+	for i := range extents {
+		limit := len(d.lines)
+		if i+1 < len(extents) {
+			limit = extents[i+1].lead
+		}
+		end := extents[i].start + 1
+		for l := end; l < limit; l++ {
+			if d.isBlank(l) {
+				continue
+			}
+			if d.indentOf(l) <= indent {
+				break
+			}
+			end = l + 1
+		}
+		extents[i].end = end
+		extents[i].next = limit
+		if i+1 == len(extents) {
+			extents[i].next = end
+		}
+	}
+	return extents
+}
+
+// entryLine finds the line of a map key or sequence dash.  Clean is
+// false if the line starts with the dash of an enclosing sequence.
+func (d *yamlDiff) entryLine(line, col, indent int) (int, bool, bool) {
+	at, ok := d.offset(line, col)
+	if !ok || at-d.lines[line-1] != indent {
+		return 0, false, false
+	}
+	prefix := d.text[d.lines[line-1]:at]
+	if len(bytes.Trim(prefix, " ")) == 0 {
+		return line - 1, true, true
+	}
+	if len(bytes.Trim(prefix, " -")) != 0 {
+		return 0, false, false
+	}
+	return line - 1, false, true
+}
+
+// offset converts a line and column, both starting at 1 and with
+// columns counted in characters, into an offset into the text
+func (d *yamlDiff) offset(line, col int) (int, bool) {
+	if line < 1 || line > len(d.lines) {
+		return 0, false
+	}
+	at := d.lines[line-1]
+	for i := 1; i < col; i++ {
+		if at >= len(d.text) || d.text[at] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(d.text[at:])
+		at += size
+	}
+	return at, at < len(d.text)
+}
+
+// at is the offset of the start of a line
+func (d *yamlDiff) at(line int) int {
+	if line >= len(d.lines) {
+		return len(d.text)
+	}
+	return d.lines[line]
+}
+
+func (d *yamlDiff) line(l int) []byte {
+	return d.text[d.at(l):d.at(l+1)]
+}
+
+func (d *yamlDiff) indentOf(l int) int {
+	line := d.line(l)
+	return len(line) - len(bytes.TrimLeft(line, " "))
+}
+
+func (d *yamlDiff) isBlank(l int) bool {
+	return len(bytes.TrimSpace(d.line(l))) == 0
+}
+
+func (d *yamlDiff) isComment(l int) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(d.line(l)), []byte{'#'})
+}
+
+// bareYAML copies a node without its head and foot comments: the
+// text of those is kept around the lines that are rewritten
+func bareYAML(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.HeadComment, c.FootComment = "", ""
+	if n.Kind != yaml.AliasNode {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, e := range n.Content {
+			c.Content[i] = bareYAML(e)
+		}
+	}
+	return &c
+}
+
+// sameYAML reports whether two nodes would be written the same way,
+// ignoring comments and positions
+func sameYAML(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Style != b.Style || a.Tag != b.Tag || a.Value != b.Value ||
+		a.Anchor != b.Anchor || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !sameYAML(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equivalentYAML reports whether two nodes hold the same data
+func equivalentYAML(a, b *yaml.Node) bool {
+	a, b = resolveYAML(a), resolveYAML(b)
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind != b.Kind || a.ShortTag() != b.ShortTag() || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode && a.ShortTag() != "!!null" && a.Value != b.Value {
+		return false
+	}
+	for i := range a.Content {
+		if !equivalentYAML(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func resolveYAML(n *yaml.Node) *yaml.Node {
+	for n != nil {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			if len(n.Content) == 0 {
+				return nil
+			}
+			n = n.Content[0]
+		case yaml.AliasNode:
+			n = n.Alias
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+// yamlIndent guesses the indentation used by a document from its
+// first nested block map
+func yamlIndent(n *yaml.Node) int {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle == 0 && len(v.Content) != 0 && v.Column > k.Column && k.Line != 0 {
+				return v.Column - k.Column
+			}
+		}
+	}
+	for _, c := range n.Content {
+		if indent := yamlIndent(c); indent != 0 {
+			return indent
+		}
+	}
+	if n.Kind == yaml.DocumentNode {
+		return 2
+	}
+	return 0
+}
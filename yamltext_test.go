@@ -0,0 +1,176 @@
+package nflex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const curated = `# Service configuration
+# maintained by ops
+
+name: api   # the service name
+version: '1.2.3'
+
+defaults: &defaults
+  timeout: 30s   # seconds
+  retries: 3
+
+servers:
+  # primary
+  - host: a.example.com
+    port: 8080
+  # backup
+  - host: b.example.com
+    port: 8081
+
+production:
+  settings: *defaults
+  replicas: 4 # scaled in March
+
+tags: [web, api]
+`
+
+func TestYAMLRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		edit func(m MutableSource) error
+		want string
+	}{
+		{
+			name: "unchanged",
+			edit: func(m MutableSource) error { return nil },
+			want: curated,
+		},
+		{
+			name: "scalars",
+			edit: func(m MutableSource) error {
+				return firstError(
+					m.Set("1.2.4", "version"),
+					m.Set(5, "defaults", "retries"),
+					m.Set("c.example.com", "servers", "1", "host"),
+				)
+			},
+			want: strings.NewReplacer(
+				"'1.2.3'", "'1.2.4'",
+				"retries: 3", "retries: 5",
+				"b.example.com", "c.example.com",
+			).Replace(curated),
+		},
+		{
+			name: "quoting",
+			edit: func(m MutableSource) error { return m.Set("true", "name") },
+			want: strings.Replace(curated, "name: api   #", `name: "true"   #`, 1),
+		},
+		{
+			name: "add keys",
+			edit: func(m MutableSource) error {
+				return firstError(
+					m.Set("debug", "production", "log"),
+					m.Set(true, "features", "beta"),
+				)
+			},
+			want: strings.Replace(curated, "scaled in March\n", "scaled in March\n  log: debug\n", 1) +
+				"features:\n  beta: true\n",
+		},
+		{
+			name: "delete",
+			edit: func(m MutableSource) error {
+				return firstError(
+					m.Delete("servers", "0"),
+					m.Delete("version"),
+					m.Delete("tags"),
+				)
+			},
+			want: strings.NewReplacer(
+				"version: '1.2.3'\n\n", "",
+				"  # primary\n  - host: a.example.com\n    port: 8080\n", "",
+				"tags: [web, api]\n", "",
+			).Replace(curated),
+		},
+		{
+			name: "append",
+			edit: func(m MutableSource) error {
+				return m.Set(map[string]interface{}{"host": "d.example.com"}, "servers", "2")
+			},
+			want: strings.Replace(curated, "port: 8081\n", "port: 8081\n  - host: d.example.com\n", 1),
+		},
+		{
+			name: "replace with map",
+			edit: func(m MutableSource) error {
+				return m.Set(map[string]interface{}{"first": "api"}, "name")
+			},
+			want: strings.Replace(curated, "name: api   # the service name\n", "name: # the service name\n  first: api\n", 1),
+		},
+		{
+			name: "flow",
+			edit: func(m MutableSource) error { return m.Set("db", "tags", "2") },
+			want: strings.Replace(curated, "tags: [web, api]\n", "tags: [web, api, db]\n", 1),
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := UnmarshalYAML([]byte(curated))
+			require.NoError(t, err)
+			require.NoError(t, tc.edit(source.(MutableSource)))
+			got, err := MarshalYAML(source)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+
+			back, err := UnmarshalYAML(got)
+			require.NoError(t, err)
+			want, err := MarshalJSON(source)
+			require.NoError(t, err)
+			again, err := MarshalJSON(back)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(again), "same data")
+		})
+	}
+}
+
+func TestYAMLRoundTripAnchors(t *testing.T) {
+	source, err := UnmarshalYAML([]byte(curated))
+	require.NoError(t, err)
+	m := source.(MutableSource)
+	require.NoError(t, m.Set(map[string]interface{}{"timeout": "1m"}, "defaults"))
+	assert.Equal(t, "1m", getString(t, source, "production", "settings", "timeout"), "alias follows the edit")
+
+	got, err := MarshalYAML(source)
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "defaults: &defaults\n  timeout: 1m\n\nservers:\n")
+	assert.Contains(t, string(got), "# Service configuration\n# maintained by ops\n\nname: api   # the service name\n")
+	assert.Contains(t, string(got), "  settings: *defaults\n  replicas: 4 # scaled in March\n")
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestYAMLFirstDocument(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"a: 1\n---\nb: 2\n", "a: 1\n"},
+		{"# head\n---\na: 1 # one\n...\n---\nb: 2\n", "# head\n---\na: 1 # one\n"},
+		{"%YAML 1.1\n---\na: |\n  ---\n  x\n--- b\n", "%YAML 1.1\n---\na: |\n  ---\n  x\n"},
+		{"---\n---\nb: 2\n", "---\n"},
+		{"a: 1", "a: 1"},
+	}
+	for _, tc := range cases {
+		s, err := UnmarshalYAML([]byte(tc.text))
+		require.NoError(t, err, tc.text)
+		got, err := MarshalYAML(s)
+		require.NoError(t, err, tc.text)
+		assert.Equal(t, tc.want, string(got), tc.text)
+		assert.False(t, s.Exists("b"), tc.text)
+	}
+}